@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// newStats returns the statistics for values, which must be non-empty.
+// The standard deviation is the sample standard deviation.
+func newStats(values []float64) gobench.Stats {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	s := gobench.Stats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Median: median(sorted),
+		Max:    sorted[len(sorted)-1],
+	}
+	if len(sorted) > 1 {
+		var sum float64
+		for _, v := range sorted {
+			sum += v
+		}
+		mean := sum / float64(len(sorted))
+		var sumSquares float64
+		for _, v := range sorted {
+			sumSquares += (v - mean) * (v - mean)
+		}
+		s.StdDev = math.Sqrt(sumSquares / float64(len(sorted)-1))
+	}
+	return s
+}
+
+// median returns the median of sorted, which must be non-empty.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// aggregateFormat is an outputFormat which buffers the results of each
+// benchmark, and on flush encodes a single result per benchmark with out.
+// Each aggregated result has the total iterations and the median of each
+// metric across the runs, and statistics for ns/op.
+type aggregateFormat struct {
+	out    outputFormat
+	groups map[aggregateKey]*aggregateGroup
+	order  []aggregateKey
+}
+
+type aggregateKey struct {
+	name, pkg, goos, goarch, cpu string
+}
+
+type aggregateGroup struct {
+	runs      []gobench.Benchmark
+	tags      map[string]string
+	timestamp time.Time
+}
+
+func newAggregateFormat(out outputFormat) *aggregateFormat {
+	return &aggregateFormat{out: out, groups: make(map[aggregateKey]*aggregateGroup)}
+}
+
+func (f *aggregateFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	key := aggregateKey{name: b.Name, pkg: pkg, goos: goos, goarch: goarch, cpu: cpu}
+	group, ok := f.groups[key]
+	if !ok {
+		group = &aggregateGroup{tags: tags, timestamp: timestamp}
+		f.groups[key] = group
+		f.order = append(f.order, key)
+	}
+	group.runs = append(group.runs, b)
+	return nil
+}
+
+func (f *aggregateFormat) flush() error {
+	for _, key := range f.order {
+		group := f.groups[key]
+		b := aggregate(group.runs)
+		if err := f.out.encode(b, key.pkg, key.goos, key.goarch, key.cpu, group.tags, group.timestamp); err != nil {
+			return err
+		}
+	}
+	return f.out.flush()
+}
+
+// aggregate combines repeated runs of a benchmark into a single result.
+func aggregate(runs []gobench.Benchmark) gobench.Benchmark {
+	result := gobench.Benchmark{Benchmark: parse.Benchmark{Name: runs[0].Name}}
+	metrics := map[int][]float64{}
+	extra := map[string][]float64{}
+	for _, run := range runs {
+		result.N += run.N
+		result.Measured |= run.Measured
+		for _, m := range []struct {
+			flag  int
+			value float64
+		}{
+			{parse.NsPerOp, run.NsPerOp},
+			{parse.MBPerS, run.MBPerS},
+			{parse.AllocedBytesPerOp, float64(run.AllocedBytesPerOp)},
+			{parse.AllocsPerOp, float64(run.AllocsPerOp)},
+		} {
+			if run.Measured&m.flag != 0 {
+				metrics[m.flag] = append(metrics[m.flag], m.value)
+			}
+		}
+		for k, v := range run.Extra {
+			extra[k] = append(extra[k], v)
+		}
+	}
+	if values := metrics[parse.NsPerOp]; len(values) > 0 {
+		s := newStats(values)
+		result.NsPerOp = s.Median
+		result.NsPerOpStats = &s
+	}
+	if values := metrics[parse.MBPerS]; len(values) > 0 {
+		result.MBPerS = newStats(values).Median
+	}
+	if values := metrics[parse.AllocedBytesPerOp]; len(values) > 0 {
+		result.AllocedBytesPerOp = uint64(newStats(values).Median)
+	}
+	if values := metrics[parse.AllocsPerOp]; len(values) > 0 {
+		result.AllocsPerOp = uint64(newStats(values).Median)
+	}
+	if len(extra) > 0 {
+		result.Extra = make(map[string]float64, len(extra))
+		for k, values := range extra {
+			result.Extra[k] = newStats(values).Median
+		}
+	}
+	return result
+}
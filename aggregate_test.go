@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newStats(t *testing.T) {
+	s := newStats([]float64{4, 2, 8, 6})
+	assert.Equal(t, 4, s.Count)
+	assert.Equal(t, 2.0, s.Min)
+	assert.Equal(t, 5.0, s.Median)
+	assert.Equal(t, 8.0, s.Max)
+	assert.InDelta(t, 2.5820, s.StdDev, 0.0001)
+
+	s = newStats([]float64{3})
+	assert.Equal(t, gobench.Stats{Count: 1, Min: 3, Median: 3, Max: 3}, s)
+}
+
+func Test_encodeBenchmarksAggregate(t *testing.T) {
+	input := `pkg: example.com/foo
+BenchmarkFoo-8   	100	10 ns/op	100 B/op	2 allocs/op
+BenchmarkBar-8   	100	5 ns/op
+BenchmarkFoo-8   	100	30 ns/op	300 B/op	2 allocs/op
+BenchmarkFoo-8   	100	20 ns/op	200 B/op	2 allocs/op
+BenchmarkFoo-8   	100	15 ns/op	150 B/op	2 allocs/op
+BenchmarkFoo-8   	100	25 ns/op	250 B/op	2 allocs/op
+`
+	docs := encodeDocs(t, inputConfig{aggregate: true}, input)
+	require.Len(t, docs, 2)
+
+	foo := docs[0]
+	assert.Equal(t, "BenchmarkFoo", foo[gobench.FieldName])
+	assert.Equal(t, 500.0, foo[gobench.FieldIterations])
+	assert.Equal(t, 20.0, foo[gobench.FieldNSPerOp])
+	assert.Equal(t, 200.0, foo[gobench.FieldAllocedBytesPerOp])
+	assert.Equal(t, 2.0, foo[gobench.FieldAllocsPerOp])
+	fooStats := foo[gobench.FieldNSPerOpStats].(map[string]interface{})
+	assert.Equal(t, 5.0, fooStats["count"])
+	assert.Equal(t, 10.0, fooStats["min"])
+	assert.Equal(t, 20.0, fooStats["median"])
+	assert.Equal(t, 30.0, fooStats["max"])
+	assert.InDelta(t, 7.9057, fooStats["stddev"], 0.0001)
+
+	bar := docs[1]
+	assert.Equal(t, "BenchmarkBar", bar[gobench.FieldName])
+	assert.Equal(t, 5.0, bar[gobench.FieldNSPerOp])
+	assert.Equal(t, map[string]interface{}{
+		"count": 1.0, "min": 5.0, "median": 5.0, "max": 5.0, "stddev": 0.0,
+	}, bar[gobench.FieldNSPerOpStats])
+}
@@ -0,0 +1,236 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/pkg/errors"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// reportBenchstat is the value of -report which writes a benchstat-style
+// comparison table.
+const reportBenchstat = "benchstat"
+
+// benchmarkKey identifies a benchmark across runs.
+type benchmarkKey struct {
+	pkg  string
+	name string
+}
+
+func (k benchmarkKey) String() string {
+	if k.pkg == "" {
+		return k.name
+	}
+	return k.pkg + "." + k.name
+}
+
+// baselineCheck compares the ns/op of benchmarks against a baseline,
+// recording those which regressed by more than threshold percent.
+type baselineCheck struct {
+	baseline  map[benchmarkKey]float64
+	threshold float64
+
+	regressions []string
+	new         []benchmarkKey
+
+	// results holds the ns/op of each benchmark recorded, for
+	// writeBenchstat.
+	results map[benchmarkKey][]float64
+}
+
+// loadBaseline reads the documents in path, which may be NDJSON as written
+// by gobench (including bulk action lines, which are ignored) or a JSON
+// array of documents, and returns a baselineCheck comparing against them.
+func loadBaseline(path string, threshold float64) (*baselineCheck, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	check := &baselineCheck{baseline: make(map[benchmarkKey]float64), threshold: threshold}
+	addDoc := func(doc map[string]interface{}) {
+		// Documents written before sub-benchmark names were split
+		// have only the full name in the name field.
+		name, _ := doc[gobench.FieldFullName].(string)
+		if name == "" {
+			name, _ = doc[gobench.FieldName].(string)
+		}
+		nsPerOp, ok := doc[gobench.FieldNSPerOp].(float64)
+		if name == "" || !ok {
+			return
+		}
+		pkg, _ := doc[gobench.FieldPkg].(string)
+		check.baseline[benchmarkKey{pkg: pkg, name: name}] = nsPerOp
+	}
+	decoder := json.NewDecoder(f)
+	for {
+		var value interface{}
+		if err := decoder.Decode(&value); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "error reading baseline %s", path)
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			addDoc(value)
+		case []interface{}:
+			for _, elem := range value {
+				if doc, ok := elem.(map[string]interface{}); ok {
+					addDoc(doc)
+				}
+			}
+		}
+	}
+	return check, nil
+}
+
+// record compares the result of a benchmark against the baseline.
+func (c *baselineCheck) record(pkg, name string, nsPerOp float64) {
+	key := benchmarkKey{pkg: pkg, name: name}
+	if c.results == nil {
+		c.results = make(map[benchmarkKey][]float64)
+	}
+	c.results[key] = append(c.results[key], nsPerOp)
+	baseline, ok := c.baseline[key]
+	if !ok {
+		c.new = append(c.new, key)
+		return
+	}
+	if baseline <= 0 {
+		return
+	}
+	change := (nsPerOp - baseline) / baseline * 100
+	if change > c.threshold {
+		c.regressions = append(c.regressions, fmt.Sprintf(
+			"%s: %.2f ns/op -> %.2f ns/op (+%.1f%%)", key, baseline, nsPerOp, change,
+		))
+	}
+}
+
+// err logs any benchmarks which were not in the baseline, and returns an
+// error listing the regressed benchmarks, if any. It is safe to call err
+// on a nil baselineCheck.
+func (c *baselineCheck) err() error {
+	if c == nil {
+		return nil
+	}
+	for _, key := range c.new {
+		slog.Info("new benchmark not in baseline", "benchmark", key.String())
+	}
+	if len(c.regressions) == 0 {
+		return nil
+	}
+	sort.Strings(c.regressions)
+	return errors.Errorf(
+		"%d benchmark(s) regressed by more than %g%%:\n\t%s",
+		len(c.regressions), c.threshold, strings.Join(c.regressions, "\n\t"),
+	)
+}
+
+// writeBenchstat writes a table in the style of benchstat to w, grouped
+// by package, comparing the baseline and recorded ns/op of each benchmark,
+// averaged over repeated runs, with the percentage change. Benchmarks not
+// in the baseline are shown with "-" in place of the old ns/op and delta.
+func (c *baselineCheck) writeBenchstat(w io.Writer) error {
+	keys := make([]benchmarkKey, 0, len(c.results))
+	for key := range c.results {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pkg != keys[j].pkg {
+			return keys[i].pkg < keys[j].pkg
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, key := range keys {
+		if i == 0 || key.pkg != keys[i-1].pkg {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+			if key.pkg != "" {
+				fmt.Fprintf(tw, "pkg: %s\n", key.pkg)
+			}
+			fmt.Fprintln(tw, "name\told ns/op\tnew ns/op\tdelta")
+		}
+		var sum float64
+		for _, nsPerOp := range c.results[key] {
+			sum += nsPerOp
+		}
+		current := sum / float64(len(c.results[key]))
+		old, delta := "-", "-"
+		if baseline, ok := c.baseline[key]; ok {
+			old = formatNsPerOp(baseline)
+			if baseline > 0 {
+				delta = fmt.Sprintf("%+.2f%%", (current-baseline)/baseline*100)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", key.name, old, formatNsPerOp(current), delta)
+	}
+	return tw.Flush()
+}
+
+// formatNsPerOp formats ns/op for writeBenchstat, with two decimal places
+// below 100ns/op, where they are significant, and none above.
+func formatNsPerOp(nsPerOp float64) string {
+	if nsPerOp < 100 {
+		return strconv.FormatFloat(nsPerOp, 'f', 2, 64)
+	}
+	return strconv.FormatFloat(nsPerOp, 'f', 0, 64)
+}
+
+// wrap returns an outputFormat which records each benchmark with c before
+// encoding it with out. If c is nil, out is returned.
+func (c *baselineCheck) wrap(out outputFormat) outputFormat {
+	if c == nil {
+		return out
+	}
+	return baselineCheckFormat{outputFormat: out, check: c}
+}
+
+type baselineCheckFormat struct {
+	outputFormat
+	check *baselineCheck
+}
+
+func (f baselineCheckFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	name, _ := gobench.SplitGOMAXPROCS(b.Name)
+	if b.Measured&parse.NsPerOp != 0 {
+		f.check.record(pkg, name, b.NsPerOp)
+	}
+	return f.outputFormat.encode(b, pkg, goos, goarch, cpu, tags, timestamp)
+}
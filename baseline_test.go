@@ -0,0 +1,122 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baselineInput = `pkg: example.com/foo
+BenchmarkFast-8   	1000000	      1000 ns/op
+BenchmarkSlow-8   	   1000	   1000000 ns/op
+`
+
+func writeBaseline(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "baseline.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func runBaseline(t *testing.T, baseline, input string) (*baselineCheck, error) {
+	check, err := loadBaseline(writeBaseline(t, baseline), 10)
+	require.NoError(t, err)
+	var stdout bytes.Buffer
+	err = output(context.Background(), inputConfig{es: gobench.Config{Index: "gobench"}}, strings.NewReader(input), &stdout, check, new(summary))
+	require.NoError(t, err)
+	return check, check.err()
+}
+
+func Test_baselineCheckClean(t *testing.T) {
+	check, err := runBaseline(t, `{"index":{"_index":"gobench"}}
+{"name":"BenchmarkFast","pkg":"example.com/foo","ns_per_op":950}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkSlow","pkg":"example.com/foo","ns_per_op":950000}
+`, baselineInput)
+	assert.NoError(t, err)
+	assert.Empty(t, check.new)
+}
+
+func Test_baselineCheckRegressed(t *testing.T) {
+	_, err := runBaseline(t, `[
+		{"name":"BenchmarkFast","pkg":"example.com/foo","ns_per_op":950},
+		{"name":"BenchmarkSlow","pkg":"example.com/foo","ns_per_op":500000}
+	]`, baselineInput)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 benchmark(s) regressed by more than 10%")
+	assert.Contains(t, err.Error(), "example.com/foo.BenchmarkSlow: 500000.00 ns/op -> 1000000.00 ns/op (+100.0%)")
+	assert.NotContains(t, err.Error(), "BenchmarkFast")
+}
+
+func Test_baselineCheckNew(t *testing.T) {
+	// BenchmarkSlow is absent from the baseline, and BenchmarkFast is
+	// present only under a different package.
+	check, err := runBaseline(t, `{"name":"BenchmarkFast","pkg":"example.com/bar","ns_per_op":1}
+`, baselineInput)
+	assert.NoError(t, err)
+	assert.Equal(t, []benchmarkKey{
+		{pkg: "example.com/foo", name: "BenchmarkFast"},
+		{pkg: "example.com/foo", name: "BenchmarkSlow"},
+	}, check.new)
+}
+
+func Test_loadBaselineMalformed(t *testing.T) {
+	_, err := loadBaseline(writeBaseline(t, `{"name":`), 10)
+	assert.Error(t, err)
+}
+
+func Test_baselineCheckBenchstat(t *testing.T) {
+	check, err := loadBaseline(writeBaseline(t, `[
+		{"name":"BenchmarkFast","pkg":"example.com/foo","ns_per_op":40},
+		{"name":"BenchmarkSlow","pkg":"example.com/foo","ns_per_op":1250000},
+		{"name":"BenchmarkEncode","pkg":"example.com/bar","ns_per_op":2000}
+	]`), 10)
+	require.NoError(t, err)
+	input := `pkg: example.com/foo
+BenchmarkFast-8   	1000000	        50 ns/op
+BenchmarkSlow-8   	   1000	   1000000 ns/op
+BenchmarkNew-8    	   1000	      1500 ns/op
+pkg: example.com/bar
+BenchmarkEncode-8 	   1000	      2100 ns/op
+BenchmarkEncode-8 	   1000	      2300 ns/op
+`
+	err = output(context.Background(), inputConfig{es: gobench.Config{Index: "gobench"}}, strings.NewReader(input), io.Discard, check, new(summary))
+	require.NoError(t, err)
+
+	var report bytes.Buffer
+	require.NoError(t, check.writeBenchstat(&report))
+	assert.Equal(t, `pkg: example.com/bar
+name             old ns/op  new ns/op  delta
+BenchmarkEncode  2000       2200       +10.00%
+
+pkg: example.com/foo
+name           old ns/op  new ns/op  delta
+BenchmarkFast  40.00      50.00      +25.00%
+BenchmarkNew   -          1500       -
+BenchmarkSlow  1250000    1000000    -20.00%
+`, report.String())
+}
@@ -0,0 +1,146 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// flagGroup is a set of groups of related flags, which are accepted by
+// the commands to which they apply.
+type flagGroup int
+
+const (
+	// commonFlags control logging and the overall run.
+	commonFlags flagGroup = 1 << iota
+	// documentFlags control the content of documents, such as tags.
+	documentFlags
+	// inputFlags control how benchmark output is read.
+	inputFlags
+	// esFlags control indexing into Elasticsearch.
+	esFlags
+	// outputFlags control output other than to Elasticsearch.
+	outputFlags
+	// uploadFlags holds -upload-file.
+	uploadFlags
+	// baselineFlags control comparison against a baseline.
+	baselineFlags
+
+	allFlags = commonFlags | documentFlags | inputFlags | esFlags | outputFlags | uploadFlags | baselineFlags
+)
+
+// command is a gobench subcommand, which accepts only the flags that
+// apply to it.
+type command struct {
+	name string
+
+	// args and description describe the command's arguments and what it
+	// does, for its usage message.
+	args        string
+	description string
+
+	// flags holds the groups of flags accepted by the command.
+	flags flagGroup
+
+	// setup, if non-nil, is called with the configuration once flags,
+	// environment variables and the configuration file have been
+	// applied, to check the command's arguments and select its mode.
+	setup func(cfg *inputConfig) error
+}
+
+// indexCommand is the default command, used when no command is given.
+var indexCommand = &command{
+	name: "index",
+	args: "[file ...]",
+	description: "Index benchmark output read from the named files, or stdin, into Elasticsearch or, " +
+		"without -es, write it to stdout. This is the default when no command is given.",
+	flags: allFlags,
+}
+
+// commands holds the gobench subcommands.
+var commands = []*command{
+	indexCommand,
+	{
+		name:        "upload",
+		args:        "file",
+		description: "Index a file of bulk NDJSON, previously written with -output-file, into the Elasticsearch cluster given by -es.",
+		flags:       commonFlags | esFlags,
+		setup: func(cfg *inputConfig) error {
+			if len(cfg.inputFiles) != 1 {
+				return errors.New("upload requires a single file of bulk NDJSON")
+			}
+			if cfg.es.URL == "" {
+				return errors.New("upload requires -es")
+			}
+			cfg.uploadFile, cfg.inputFiles = cfg.inputFiles[0], nil
+			return nil
+		},
+	},
+	{
+		name: "compare",
+		args: "[file ...]",
+		description: "Compare benchmark output read from the named files, or stdin, against the -baseline, " +
+			"exiting with a non-zero status if any benchmark regressed. Nothing is written or indexed.",
+		flags: commonFlags | inputFlags | baselineFlags,
+		setup: func(cfg *inputConfig) error {
+			if cfg.baseline == "" {
+				return errors.New("compare requires -baseline")
+			}
+			cfg.discard = true
+			return nil
+		},
+	},
+	{
+		name: "print",
+		args: "[file ...]",
+		description: "Write benchmark output read from the named files, or stdin, to stdout in the " +
+			"selected -format, or to another output such as -output-file or -sqlite, without indexing it into Elasticsearch.",
+		flags: commonFlags | documentFlags | inputFlags | outputFlags,
+	},
+}
+
+// lookupCommand returns the command named by the first of args, and the
+// remaining args. If args does not begin with a command name, it returns
+// nil and args, so that gobench may be run without a command as before
+// commands were introduced.
+func lookupCommand(args []string) (*command, []string) {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd, args[1:]
+			}
+		}
+	}
+	return nil, args
+}
+
+// newFlagSet returns a flag set for cmd, writing errors and its usage
+// message to w.
+func (cmd *command) newFlagSet(w io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("gobench "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		fmt.Fprintf(w, "Usage: gobench %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,179 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBulkServer returns a test Elasticsearch server which records the
+// body of each bulk request in bulk.
+func newBulkServer(t *testing.T, bulk *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version":{"number":"8.0.0"}}`))
+		case "/_bulk":
+			body, _ := io.ReadAll(r.Body)
+			*bulk = append(*bulk, string(body))
+			n := strings.Count(string(body), "\n") / 2
+			items := strings.TrimSuffix(strings.Repeat(`{"index":{"status":201}},`, n), ",")
+			w.Write([]byte(`{"errors":false,"items":[` + items + `]}`))
+		default:
+			w.Write([]byte(`{"acknowledged":true}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func Test_lookupCommand(t *testing.T) {
+	for _, name := range []string{"index", "upload", "compare", "print"} {
+		cmd, args := lookupCommand([]string{name, "-v", "bench.txt"})
+		require.NotNil(t, cmd, name)
+		assert.Equal(t, name, cmd.name)
+		assert.Equal(t, []string{"-v", "bench.txt"}, args)
+	}
+
+	cmd, args := lookupCommand([]string{"-v", "index"})
+	assert.Nil(t, cmd)
+	assert.Equal(t, []string{"-v", "index"}, args)
+
+	cmd, args = lookupCommand(nil)
+	assert.Nil(t, cmd)
+	assert.Empty(t, args)
+}
+
+func Test_gobenchMainIndex(t *testing.T) {
+	for name, args := range map[string][]string{
+		"default": {"-no-vcs", "-no-host"},
+		"index":   {"index", "-no-vcs", "-no-host"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var bulk []string
+			srv := newBulkServer(t, &bulk)
+			code, stdout, _ := testGobenchMain(t, summaryInput, append(args, "-es", srv.URL)...)
+			assert.Equal(t, exitOK, code)
+			assert.Empty(t, stdout)
+			require.Len(t, bulk, 1)
+			assert.Contains(t, bulk[0], `"name":"BenchmarkFoo"`)
+		})
+	}
+}
+
+func Test_gobenchMainUpload(t *testing.T) {
+	ndjson := `{"index":{"_index":"gobench"}}
+{"name":"BenchmarkFoo","ns_per_op":10}
+`
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(ndjson), 0600))
+
+	var bulk []string
+	srv := newBulkServer(t, &bulk)
+	code, _, stderr := testGobenchMain(t, "", "upload", "-es", srv.URL, path)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Equal(t, []string{ndjson}, bulk)
+
+	code, _, stderr = testGobenchMain(t, "", "upload", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "upload requires -es\n", stderr)
+
+	code, _, stderr = testGobenchMain(t, "", "upload", "-es", srv.URL)
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "upload requires a single file of bulk NDJSON\n", stderr)
+
+	// Flags which only apply to reading benchmark output are rejected.
+	code, _, stderr = testGobenchMain(t, "", "upload", "-es", srv.URL, "-tag", "team=apm", path)
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "flag provided but not defined: -tag")
+}
+
+func Test_gobenchMainCompare(t *testing.T) {
+	baseline := writeBaseline(t, `[
+		{"name":"BenchmarkFast","pkg":"example.com/foo","ns_per_op":950},
+		{"name":"BenchmarkSlow","pkg":"example.com/foo","ns_per_op":950000}
+	]`)
+
+	code, stdout, stderr := testGobenchMain(t, baselineInput, "compare", "-baseline", baseline)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Empty(t, stdout)
+
+	code, stdout, stderr = testGobenchMain(t, baselineInput, "compare", "-baseline", baseline, "-threshold", "1")
+	assert.Equal(t, exitFailure, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "2 benchmark(s) regressed by more than 1%")
+
+	code, _, stderr = testGobenchMain(t, baselineInput, "compare")
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "compare requires -baseline\n", stderr)
+
+	code, _, stderr = testGobenchMain(t, baselineInput, "compare", "-baseline", baseline, "-es", "http://localhost:9200")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "flag provided but not defined: -es")
+}
+
+func Test_gobenchMainCompareReport(t *testing.T) {
+	baseline := writeBaseline(t, `[
+		{"name":"BenchmarkFast","pkg":"example.com/foo","ns_per_op":950},
+		{"name":"BenchmarkSlow","pkg":"example.com/foo","ns_per_op":500000}
+	]`)
+	code, stdout, stderr := testGobenchMain(t, baselineInput, "compare", "-baseline", baseline, "-report", "benchstat")
+	assert.Equal(t, exitFailure, code)
+	assert.Equal(t, `pkg: example.com/foo
+name           old ns/op  new ns/op  delta
+BenchmarkFast  950        1000       +5.26%
+BenchmarkSlow  500000     1000000    +100.00%
+`, stdout)
+	assert.Contains(t, stderr, "1 benchmark(s) regressed by more than 10%")
+
+	code, _, stderr = testGobenchMain(t, baselineInput, "compare", "-baseline", baseline, "-report", "html")
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "invalid -report \"html\": must be benchstat\n", stderr)
+
+	code, _, stderr = testGobenchMain(t, baselineInput, "index", "-report", "benchstat")
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "-report requires -baseline\n", stderr)
+}
+
+func Test_gobenchMainPrint(t *testing.T) {
+	code, stdout, stderr := testGobenchMain(t, summaryInput, "print", "-no-vcs", "-no-host", "-format", "csv")
+	assert.Equal(t, exitOK, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "name,pkg,"), stdout)
+	assert.Contains(t, stdout, "BenchmarkFoo")
+
+	code, _, stderr = testGobenchMain(t, summaryInput, "print", "-es", "http://localhost:9200")
+	assert.Equal(t, exitUsage, code)
+	assert.Contains(t, stderr, "flag provided but not defined: -es")
+
+	// Settings for flags of other commands in a shared configuration
+	// file, or in the environment, are ignored.
+	t.Setenv("GOBENCH_ES_URL", "http://localhost:9200")
+	config := writeConfigFile(t, "gobench.yml", "es: http://localhost:9200\nno-vcs: true\nno-host: true\n")
+	code, stdout, stderr = testGobenchMain(t, summaryInput, "print", "-config", config)
+	assert.Equal(t, exitOK, code, stderr)
+	assert.Contains(t, stdout, `"name":"BenchmarkFoo"`)
+}
@@ -0,0 +1,546 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReadInputConfig calls readInputConfig with a new flag set.
+func testReadInputConfig(t *testing.T, args ...string) (inputConfig, error) {
+	t.Cleanup(func() { *verboseFlag = false })
+	fs := flag.NewFlagSet("gobench", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return readInputConfig(fs, args)
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func Test_readInputConfigDefaults(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Equal(t, "gobench", cfg.es.Index)
+	assert.Equal(t, formatJSON, cfg.format)
+	assert.Equal(t, inputText, cfg.input)
+	assert.Empty(t, cfg.tags)
+}
+
+func Test_readInputConfigFile(t *testing.T) {
+	configFile := writeConfigFile(t, "gobench.yml", `
+es: http://localhost:9200
+index: benchmarks
+es-username: elastic
+es-password: changeme
+compress: true
+max-retries: 5
+tag: team=apm
+tags:
+  branch: main
+  build: 123
+`)
+	cfg, err := testReadInputConfig(t, "-config", configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9200", cfg.es.URL)
+	assert.Equal(t, "benchmarks", cfg.es.Index)
+	assert.Equal(t, "elastic", cfg.es.Username)
+	assert.Equal(t, "changeme", cfg.es.Password)
+	assert.True(t, cfg.es.Compress)
+	assert.Equal(t, 5, cfg.es.MaxRetries)
+	assert.Equal(t, map[string]string{"team": "apm", "branch": "main", "build": "123"}, cfg.tags)
+}
+
+func Test_readInputConfigFileJSON(t *testing.T) {
+	configFile := writeConfigFile(t, "gobench.json", `{"es": "http://localhost:9200", "bulk-max-bytes": 1024}`)
+	cfg, err := testReadInputConfig(t, "-config", configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9200", cfg.es.URL)
+	assert.Equal(t, 1024, cfg.es.BulkMaxBytes)
+}
+
+func Test_readInputConfigFlagOverridesFile(t *testing.T) {
+	configFile := writeConfigFile(t, "gobench.yml", `
+es: http://localhost:9200
+index: benchmarks
+es-username: elastic
+tags:
+  branch: main
+  team: apm
+`)
+	cfg, err := testReadInputConfig(t,
+		"-index", "override",
+		"-config", configFile,
+		"-tag", "branch=feature",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:9200", cfg.es.URL)
+	assert.Equal(t, "override", cfg.es.Index)
+	assert.Equal(t, "elastic", cfg.es.Username)
+	assert.Equal(t, map[string]string{"branch": "feature", "team": "apm"}, cfg.tags)
+}
+
+func Test_readInputConfigFileErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"malformed":       "es: [http://localhost:9200",
+		"unknown-key":     "elasticsearch: http://localhost:9200",
+		"invalid-value":   "max-retries: lots",
+		"invalid-tags":    "tags: [a, b]",
+		"invalid-refresh": "refresh: sometimes",
+	} {
+		t.Run(name, func(t *testing.T) {
+			configFile := writeConfigFile(t, "gobench.yml", content)
+			_, err := testReadInputConfig(t, "-config", configFile)
+			assert.Error(t, err)
+		})
+	}
+	t.Run("missing", func(t *testing.T) {
+		_, err := testReadInputConfig(t, "-config", filepath.Join(t.TempDir(), "missing.yml"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_readInputConfigEnv(t *testing.T) {
+	configFile := writeConfigFile(t, "gobench.yml", `
+index: from-file
+es-username: file-user
+`)
+	for name, tc := range map[string]struct {
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg inputConfig)
+	}{
+		"env": {
+			env: map[string]string{
+				"GOBENCH_ES_URL":      "http://localhost:9200",
+				"GOBENCH_ES_USERNAME": "elastic",
+				"GOBENCH_ES_PASSWORD": "changeme",
+				"GOBENCH_INDEX":       "benchmarks",
+				"GOBENCH_COMPRESS":    "true",
+				"GOBENCH_TAGS":        "team=apm",
+			},
+			check: func(t *testing.T, cfg inputConfig) {
+				assert.Equal(t, "http://localhost:9200", cfg.es.URL)
+				assert.Equal(t, "elastic", cfg.es.Username)
+				assert.Equal(t, "changeme", cfg.es.Password)
+				assert.Equal(t, "benchmarks", cfg.es.Index)
+				assert.True(t, cfg.es.Compress)
+				assert.Equal(t, map[string]string{"team": "apm"}, cfg.tags)
+			},
+		},
+		"flag-overrides-env": {
+			env:  map[string]string{"GOBENCH_INDEX": "from-env"},
+			args: []string{"-index", "from-flag"},
+			check: func(t *testing.T, cfg inputConfig) {
+				assert.Equal(t, "from-flag", cfg.es.Index)
+			},
+		},
+		"env-overrides-file": {
+			env: map[string]string{"GOBENCH_INDEX": "from-env", configEnvVar: configFile},
+			check: func(t *testing.T, cfg inputConfig) {
+				assert.Equal(t, "from-env", cfg.es.Index)
+				assert.Equal(t, "file-user", cfg.es.Username)
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := testReadInputConfig(t, tc.args...)
+			require.NoError(t, err)
+			tc.check(t, cfg)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("GOBENCH_MAX_RETRIES", "lots")
+		_, err := testReadInputConfig(t)
+		assert.Error(t, err)
+	})
+}
+
+func Test_readInputConfigTimestamp(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-timestamp", "2024-01-15T10:30:00+01:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.January, 15, 9, 30, 0, 0, time.UTC), cfg.timestamp)
+
+	_, err = testReadInputConfig(t, "-timestamp", "2024-01-15 10:30")
+	assert.EqualError(t, err, `invalid -timestamp "2024-01-15 10:30": must be in RFC3339 format, e.g. 2006-01-02T15:04:05Z`)
+}
+
+func Test_readInputConfigWorkers(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.es.Workers)
+
+	cfg, err = testReadInputConfig(t, "-workers", "4")
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.es.Workers)
+
+	_, err = testReadInputConfig(t, "-workers", "0")
+	assert.EqualError(t, err, "invalid -workers 0: must be at least 1")
+}
+
+func Test_readInputConfigConnectionPool(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Equal(t, http.DefaultMaxIdleConnsPerHost, cfg.es.MaxIdleConnsPerHost)
+	assert.Equal(t, 90*time.Second, cfg.es.IdleConnTimeout)
+
+	cfg, err = testReadInputConfig(t, "-max-idle-conns-per-host", "8", "-idle-conn-timeout", "2m")
+	require.NoError(t, err)
+	assert.Equal(t, 8, cfg.es.MaxIdleConnsPerHost)
+	assert.Equal(t, 2*time.Minute, cfg.es.IdleConnTimeout)
+
+	_, err = testReadInputConfig(t, "-max-idle-conns-per-host", "0")
+	assert.EqualError(t, err, "invalid -max-idle-conns-per-host 0: must be at least 1")
+}
+
+func Test_readInputConfigLogging(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Equal(t, logFormatText, cfg.logFormat)
+	assert.Equal(t, slog.LevelInfo, cfg.logLevel)
+
+	cfg, err = testReadInputConfig(t, "-log-format", "json", "-log-level", "warn")
+	require.NoError(t, err)
+	assert.Equal(t, logFormatJSON, cfg.logFormat)
+	assert.Equal(t, slog.LevelWarn, cfg.logLevel)
+
+	_, err = testReadInputConfig(t, "-log-format", "xml")
+	assert.EqualError(t, err, `invalid -log-format "xml": must be text or json`)
+
+	_, err = testReadInputConfig(t, "-log-level", "loud")
+	assert.EqualError(t, err, `invalid -log-level "loud": must be debug, info, warn or error`)
+
+	cfg, err = testReadInputConfig(t, "-log-level", "error", "-v")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, cfg.logLevel)
+}
+
+func Test_readInputConfigSQLite(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-sqlite", "gobench.db")
+	require.NoError(t, err)
+	assert.Equal(t, "gobench.db", cfg.sqlite)
+
+	_, err = testReadInputConfig(t, "-sqlite", "gobench.db", "-es", "http://localhost:9200")
+	assert.EqualError(t, err, "-sqlite cannot be combined with -es")
+
+	_, err = testReadInputConfig(t, "-sqlite", "gobench.db", "-output-file", "bulk.ndjson")
+	assert.EqualError(t, err, "-sqlite cannot be combined with -output-file")
+
+	_, err = testReadInputConfig(t, "-sqlite", "gobench.db", "-format", "csv")
+	assert.EqualError(t, err, "-format csv cannot be combined with -sqlite")
+}
+
+func Test_readInputConfigPostgres(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-postgres", "postgres://localhost/db")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://localhost/db", cfg.postgres)
+	assert.Equal(t, "benchmarks", cfg.postgresTable)
+	assert.Equal(t, 500, cfg.postgresBatchSize)
+
+	_, err = testReadInputConfig(t, "-postgres", "postgres://localhost/db", "-sqlite", "gobench.db")
+	assert.EqualError(t, err, "-postgres cannot be combined with -sqlite")
+
+	_, err = testReadInputConfig(t, "-postgres", "postgres://localhost/db", "-postgres-table", "")
+	assert.EqualError(t, err, "-postgres-table must not be empty")
+
+	_, err = testReadInputConfig(t, "-postgres-batch-size", "10000")
+	assert.EqualError(t, err, "invalid -postgres-batch-size 10000: must be between 1 and 3640")
+}
+
+func Test_readInputConfigOTLP(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-otlp-endpoint", "http://localhost:4318")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:4318", cfg.otlpEndpoint)
+
+	_, err = testReadInputConfig(t, "-otlp-endpoint", "http://localhost:4318", "-es", "http://localhost:9200")
+	assert.EqualError(t, err, "-otlp-endpoint cannot be combined with -es")
+
+	_, err = testReadInputConfig(t, "-otlp-endpoint", "localhost:4318")
+	assert.EqualError(t, err, `invalid OTLP endpoint "localhost:4318": must be an http or https URL`)
+}
+
+func Test_readInputConfigRepoDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := testReadInputConfig(t, "-repo-dir", dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, cfg.docOptions.RepoDir)
+
+	_, err = testReadInputConfig(t, "-repo-dir", dir, "-no-vcs")
+	assert.EqualError(t, err, "-repo-dir cannot be combined with -no-vcs")
+
+	file := writeConfigFile(t, "foo.go", "package foo\n")
+	_, err = testReadInputConfig(t, "-repo-dir", file)
+	assert.EqualError(t, err, fmt.Sprintf("invalid -repo-dir %q: not a directory", file))
+
+	_, err = testReadInputConfig(t, "-repo-dir", filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func Test_readInputConfigTags(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args     []string
+		expected map[string]string
+	}{
+		"comma-separated": {
+			args:     []string{"-tag", "a=1, b=2"},
+			expected: map[string]string{"a": "1", "b": "2"},
+		},
+		"repeated": {
+			args:     []string{"-tag", "a=1", "-tag", "b=2"},
+			expected: map[string]string{"a": "1", "b": "2"},
+		},
+		"repeated-with-commas": {
+			args:     []string{"-tag", "subject=Fix a, b and c", "-tag", "params=x,y,z"},
+			expected: map[string]string{"subject": "Fix a, b and c", "params": "x,y,z"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := testReadInputConfig(t, tc.args...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg.tags)
+		})
+	}
+
+	_, err := testReadInputConfig(t, "-tag", "a=1", "-tag", "b")
+	assert.EqualError(t, err, `invalid key-value pair "b" in -tags: missing '='`)
+}
+
+func Test_readInputConfigTagKeys(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-tag", "go_version=go1.22", "-tag", "runner-type=c5")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"go_version": "go1.22", "runner-type": "c5"}, cfg.tags)
+
+	for name, tc := range map[string]struct {
+		args     []string
+		expected string
+	}{
+		"dotted": {
+			args:     []string{"-tag", "foo.bar=1"},
+			expected: `invalid tag key "foo.bar": must not contain '.'`,
+		},
+		"underscore": {
+			args:     []string{"-tag", "_id=1"},
+			expected: `invalid tag key "_id": must not begin with '_'`,
+		},
+		"empty": {
+			args:     []string{"-tag", "=1"},
+			expected: `invalid tag key "": must not be empty`,
+		},
+		"whitespace": {
+			args:     []string{"-tag", "build id=1"},
+			expected: `invalid tag key "build id": must not contain whitespace or control characters`,
+		},
+		"tags-file": {
+			args:     []string{"-tags-file", writeConfigFile(t, "tags", `{"ci.job": "bench"}`)},
+			expected: `invalid tag key "ci.job": must not contain '.'`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := testReadInputConfig(t, tc.args...)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func Test_readInputConfigTagsFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"json":      `{"runner": "c5.xlarge", "cores": 4, "go": "go1.22"}`,
+		"key-value": "# runner specs\nrunner=c5.xlarge\n\ncores = 4\ngo=go1.22\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			tagsFile := writeConfigFile(t, "tags", content)
+			cfg, err := testReadInputConfig(t, "-tags-file", tagsFile)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"runner": "c5.xlarge", "cores": "4", "go": "go1.22"}, cfg.tags)
+		})
+	}
+
+	t.Run("flag-overrides-file", func(t *testing.T) {
+		tagsFile := writeConfigFile(t, "tags", "runner=c5.xlarge\ngo=go1.22\n")
+		configFile := writeConfigFile(t, "gobench.yml", "tags:\n  runner: m5.large\n  team: apm\n")
+		cfg, err := testReadInputConfig(t, "-config", configFile, "-tags-file", tagsFile, "-tag", "go=go1.23")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"runner": "c5.xlarge", "go": "go1.23", "team": "apm"}, cfg.tags)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		tagsFile := writeConfigFile(t, "tags", "runner=c5.xlarge\ncores\n")
+		_, err := testReadInputConfig(t, "-tags-file", tagsFile)
+		assert.EqualError(t, err, "error reading tags file "+tagsFile+`: line 2: invalid key-value pair "cores": missing '='`)
+	})
+}
+
+func Test_readInputConfigBenchtime(t *testing.T) {
+	for _, benchtime := range []string{"1x", "100x", "10s", "1m30s", "500ms"} {
+		cfg, err := testReadInputConfig(t, "-benchtime", benchtime)
+		require.NoError(t, err, benchtime)
+		assert.Equal(t, benchtime, cfg.benchtime)
+	}
+	for _, benchtime := range []string{"0x", "x", "-1s", "10", "fast"} {
+		_, err := testReadInputConfig(t, "-benchtime", benchtime)
+		assert.EqualError(t, err, `invalid -benchtime "`+benchtime+`": must be a duration, e.g. 10s, or a number of iterations, e.g. 100x`)
+	}
+}
+
+func Test_readInputConfigDedupSequence(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-dedup", "-dedup-sequence")
+	require.NoError(t, err)
+	assert.True(t, cfg.es.DedupSequence)
+
+	_, err = testReadInputConfig(t, "-dedup-sequence")
+	assert.EqualError(t, err, "-dedup-sequence requires -dedup")
+}
+
+func Test_readInputConfigWaitForActiveShards(t *testing.T) {
+	for _, value := range []string{"1", "3", "all"} {
+		cfg, err := testReadInputConfig(t, "-wait-for-active-shards", value)
+		require.NoError(t, err)
+		assert.Equal(t, value, cfg.es.WaitForActiveShards)
+	}
+	for _, value := range []string{"0", "-1", "some", "ALL"} {
+		_, err := testReadInputConfig(t, "-wait-for-active-shards", value)
+		assert.EqualError(t, err, `invalid -wait-for-active-shards "`+value+`": must be a positive integer or all`)
+	}
+}
+
+func Test_readInputConfigShardsReplicas(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Zero(t, cfg.es.Shards)
+	assert.Nil(t, cfg.es.Replicas)
+
+	cfg, err = testReadInputConfig(t, "-shards", "3", "-replicas", "0")
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.es.Shards)
+	require.NotNil(t, cfg.es.Replicas)
+	assert.Equal(t, 0, *cfg.es.Replicas)
+
+	for args, expected := range map[[2]string]string{
+		{"-shards", "0"}:     `invalid -shards "0": must be a positive integer`,
+		{"-shards", "one"}:   `invalid -shards "one": must be a positive integer`,
+		{"-replicas", "-1"}:  `invalid -replicas "-1": must be a non-negative integer`,
+		{"-replicas", "1.5"}: `invalid -replicas "1.5": must be a non-negative integer`,
+	} {
+		_, err := testReadInputConfig(t, args[0], args[1])
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func Test_readInputConfigIndexName(t *testing.T) {
+	_, err := testReadInputConfig(t, "-index", "Benchmarks")
+	assert.EqualError(t, err, `invalid index name "Benchmarks": must be lowercase`)
+	_, err = testReadInputConfig(t, "-index", "_benchmarks")
+	assert.EqualError(t, err, `invalid index name "_benchmarks": must not begin with '-', '_' or '+'`)
+	cfg, err := testReadInputConfig(t, "-index", "benchmarks-{2006.01}")
+	require.NoError(t, err)
+	assert.Equal(t, "benchmarks-{2006.01}", cfg.es.Index)
+}
+
+func Test_readInputConfigAlias(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-alias", "gobench-write", "-auto-rollover", "-rollover-max-docs", "1000")
+	require.NoError(t, err)
+	assert.Equal(t, "gobench-write", cfg.es.Alias)
+	assert.True(t, cfg.es.AutoRollover)
+	assert.Equal(t, 1000, cfg.es.RolloverMaxDocs)
+
+	for _, test := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-alias", "Write"}, `invalid -alias: invalid index name "Write": must be lowercase`},
+		{[]string{"-alias", "w", "-per-package-index"}, "-alias and -per-package-index are mutually exclusive"},
+		{[]string{"-alias", "w", "-index-from-tag", "team"}, "-alias and -index-from-tag are mutually exclusive"},
+		{[]string{"-alias", "w", "-index", "gobench-{2006.01}"}, `-alias cannot be combined with the date pattern in -index "gobench-{2006.01}"`},
+		{[]string{"-auto-rollover", "-rollover-max-age", "7d"}, "-auto-rollover requires -alias"},
+		{[]string{"-alias", "w", "-auto-rollover"}, "-auto-rollover requires -rollover-max-age, -rollover-max-docs and/or -rollover-max-size"},
+		{[]string{"-alias", "w", "-rollover-max-size", "1gb"}, "-rollover-max-age, -rollover-max-docs and -rollover-max-size require -auto-rollover"},
+	} {
+		_, err := testReadInputConfig(t, test.args...)
+		assert.EqualError(t, err, test.err, "%v", test.args)
+	}
+}
+
+func Test_readInputConfigILMPolicy(t *testing.T) {
+	_, err := testReadInputConfig(t, "-ilm-policy-name", "p", "-ilm-max-age", "30d")
+	assert.EqualError(t, err, "invalid -ilm-policy-name: ILM policy requires an alias to roll over, or an index template to delete indices by age")
+	_, err = testReadInputConfig(t, "-ilm-policy-name", "p", "-ilm-max-size", "50gb", "-use-template")
+	assert.EqualError(t, err, "invalid -ilm-policy-name: ILM max size requires an alias to roll over")
+	_, err = testReadInputConfig(t, "-ilm-policy-name", "p", "-ilm-max-age", "30d", "-use-template")
+	assert.NoError(t, err)
+	_, err = testReadInputConfig(t, "-ilm-policy-name", "p", "-ilm-max-size", "50gb", "-alias", "gobench-write")
+	assert.NoError(t, err)
+}
+
+func Test_readInputConfigMappingOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"properties":{"name":{"fields":{"text":{"type":"text"}}}}}`), 0644))
+	cfg, err := testReadInputConfig(t, "-mapping-overrides", path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"name": map[string]interface{}{"fields": map[string]interface{}{"text": map[string]interface{}{"type": "text"}}},
+	}, cfg.es.MappingOverrides)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	_, err = testReadInputConfig(t, "-mapping-overrides", path)
+	assert.EqualError(t, err, `invalid -mapping-overrides `+path+`: missing "properties" object`)
+}
+
+func Test_readInputConfigTimeouts(t *testing.T) {
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.es.DialTimeout)
+	assert.Equal(t, 10*time.Second, cfg.es.TLSHandshakeTimeout)
+	assert.Equal(t, 2*time.Minute, cfg.es.ResponseHeaderTimeout)
+
+	cfg, err = testReadInputConfig(t, "-response-header-timeout", "5m")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cfg.es.ResponseHeaderTimeout)
+
+	_, err = testReadInputConfig(t, "-dial-timeout", "0s")
+	assert.EqualError(t, err, "invalid -dial-timeout 0s: must be positive")
+}
+
+func Test_readInputConfigServerless(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-es", "https://example.es.io", "-serverless")
+	require.NoError(t, err)
+	assert.True(t, cfg.es.Serverless)
+
+	_, err = testReadInputConfig(t, "-serverless")
+	assert.EqualError(t, err, "-serverless requires -es")
+	_, err = testReadInputConfig(t, "-es", "https://example.es.io", "-serverless", "-replicas", "0")
+	assert.EqualError(t, err, "-shards and -replicas are not supported with -serverless")
+	_, err = testReadInputConfig(t, "-es", "https://example.es.io", "-serverless", "-use-template", "-ilm-policy-name", "p", "-ilm-max-age", "30d")
+	assert.EqualError(t, err, "-ilm-policy-name is not supported with -serverless")
+}
@@ -0,0 +1,121 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// csvColumns are the fixed leading columns written by csvFormat.
+var csvColumns = []string{
+	gobench.FieldName,
+	gobench.FieldPkg,
+	gobench.FieldIterations,
+	gobench.FieldNSPerOp,
+	gobench.FieldMBPerS,
+	gobench.FieldAllocedBytesPerOp,
+	gobench.FieldAllocsPerOp,
+}
+
+// csvFormat encodes benchmark results as CSV, with a header row followed
+// by one row per benchmark.
+//
+// The fixed columns are followed by one column per tag, ordered by tag
+// key and named "tags.<key>" so that tags cannot be confused with the
+// fixed columns, and a final extra_metrics column. Since the set of extra metrics
+// may vary from one benchmark to the next, they are written as a JSON
+// object, e.g. {"events_sec":15988}. Metrics that were not measured are
+// left empty.
+type csvFormat struct {
+	w       *csv.Writer
+	tagKeys []string
+	header  bool
+}
+
+func (f *csvFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	if !f.header {
+		for key := range tags {
+			f.tagKeys = append(f.tagKeys, key)
+		}
+		sort.Strings(f.tagKeys)
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, 0, len(csvColumns)+len(f.tagKeys)+1)
+	record = append(record, b.Name, pkg, strconv.Itoa(b.N))
+	record = append(record, csvMetric(b, parse.NsPerOp, strconv.FormatFloat(b.NsPerOp, 'f', -1, 64)))
+	record = append(record, csvMetric(b, parse.MBPerS, strconv.FormatFloat(b.MBPerS, 'f', -1, 64)))
+	record = append(record, csvMetric(b, parse.AllocedBytesPerOp, strconv.FormatUint(b.AllocedBytesPerOp, 10)))
+	record = append(record, csvMetric(b, parse.AllocsPerOp, strconv.FormatUint(b.AllocsPerOp, 10)))
+	for _, key := range f.tagKeys {
+		record = append(record, tags[key])
+	}
+	var extra string
+	if len(b.Extra) > 0 {
+		data, err := json.Marshal(b.Extra)
+		if err != nil {
+			return err
+		}
+		extra = string(data)
+	}
+	record = append(record, extra)
+	return f.w.Write(record)
+}
+
+func (f *csvFormat) writeHeader() error {
+	f.header = true
+	header := append([]string{}, csvColumns...)
+	for _, key := range f.tagKeys {
+		header = append(header, gobench.FieldTags+"."+key)
+	}
+	header = append(header, gobench.FieldExtraMetrics)
+	return f.w.Write(header)
+}
+
+func (f *csvFormat) flush() error {
+	if !f.header {
+		if err := f.writeHeader(); err != nil {
+			return err
+		}
+	}
+	f.w.Flush()
+	return f.w.Error()
+}
+
+// csvMetric returns value if the benchmark measured the given metric,
+// and the empty string otherwise.
+func csvMetric(b gobench.Benchmark, metric int, value string) string {
+	if b.Measured&metric == 0 {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_csvFormat(t *testing.T) {
+	input, err := os.Open("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	defer input.Close()
+
+	var buf bytes.Buffer
+	out, err := newOutputFormat(formatCSV, &buf, gobench.Config{}, nil, gobench.DocumentOptions{})
+	require.NoError(t, err)
+	cfg := inputConfig{tags: map[string]string{"team": "apm", "comment": "a, \"quoted\" value"}}
+	require.NoError(t, encodeBenchmarks(cfg, input, out, new(summary)))
+
+	expected, err := os.ReadFile("testdata/benchmark-result.csv")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
+
+func Test_csvFormatEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	out, err := newOutputFormat(formatCSV, &buf, gobench.Config{}, nil, gobench.DocumentOptions{})
+	require.NoError(t, err)
+	require.NoError(t, out.flush())
+	assert.Equal(t, "name,pkg,iterations,ns_per_op,mb_per_s,alloced_bytes_per_op,allocs_per_op,extra_metrics\n", buf.String())
+}
+
+func Test_csvFormatTagColumns(t *testing.T) {
+	var buf bytes.Buffer
+	out, err := newOutputFormat(formatCSV, &buf, gobench.Config{}, nil, gobench.DocumentOptions{})
+	require.NoError(t, err)
+	b := gobench.Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo", N: 10}}
+	require.NoError(t, out.encode(b, "example.com/pkg", "", "", "", map[string]string{"name": "x", "ns_per_op": "y"}, time.Now()))
+	require.NoError(t, out.flush())
+	assert.Equal(t, "name,pkg,iterations,ns_per_op,mb_per_s,alloced_bytes_per_op,allocs_per_op,tags.name,tags.ns_per_op,extra_metrics\n"+
+		"BenchmarkFoo,example.com/pkg,10,,,,,x,y,\n", buf.String())
+}
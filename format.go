@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/elastic/gobench/gobench"
+	"github.com/pkg/errors"
+)
+
+const (
+	formatJSON       = "json"
+	formatInfluxDB   = "influxdb"
+	formatPrometheus = "prometheus"
+	formatCSV        = "csv"
+)
+
+// outputFormats lists the supported values of the -format flag.
+var outputFormats = []string{formatJSON, formatInfluxDB, formatPrometheus, formatCSV}
+
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFormatTags returns an error if the key of any of tags is also
+// used by format for the details of each benchmark, which the tag would
+// otherwise replace.
+func validateFormatTags(format string, tags map[string]string) error {
+	var reserved []string
+	normalize := func(key string) string { return key }
+	switch format {
+	case formatInfluxDB:
+		reserved = influxDBCoreTags
+	case formatPrometheus:
+		reserved = prometheusCoreLabels
+		normalize = sanitizePrometheusName
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if containsString(reserved, normalize(key)) {
+			return errors.Errorf("invalid tag key %q: reserved by -format %s", key, format)
+		}
+	}
+	return nil
+}
+
+// outputFormat encodes benchmark results to an output stream.
+type outputFormat interface {
+	encode(
+		b gobench.Benchmark,
+		pkg, goos, goarch, cpu string,
+		tags map[string]string,
+		timestamp time.Time,
+	) error
+
+	// flush writes any buffered output, after all benchmarks
+	// have been encoded.
+	flush() error
+}
+
+// newOutputFormat returns an outputFormat which writes to w in the
+// named format. Formats which write documents create them with opts.
+func newOutputFormat(
+	format string,
+	w io.Writer,
+	cfg gobench.Config,
+	esVersion *semver.Version,
+	opts gobench.DocumentOptions,
+) (outputFormat, error) {
+	switch format {
+	case "", formatJSON:
+		return documentFormat{output: gobench.NewBulkOutput(w, cfg, esVersion), opts: opts}, nil
+	case formatInfluxDB:
+		return influxDBFormat{w: w}, nil
+	case formatPrometheus:
+		return newPrometheusFormat(w), nil
+	case formatCSV:
+		return &csvFormat{w: csv.NewWriter(w)}, nil
+	}
+	return nil, errors.Errorf("unknown output format %q", format)
+}
+
+// discardFormat is an outputFormat which discards the benchmarks.
+type discardFormat struct{}
+
+func (discardFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	return nil
+}
+
+func (discardFormat) flush() error { return nil }
+
+// documentFormat converts benchmark results into documents, and writes
+// them to an Output.
+type documentFormat struct {
+	output gobench.Output
+	opts   gobench.DocumentOptions
+}
+
+func (f documentFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	return f.output.Write(f.opts.NewDocument(b, pkg, goos, goarch, cpu, tags, timestamp))
+}
+
+func (f documentFormat) flush() error {
+	return f.output.Flush()
+}
+
+// multiOutput is an Output which writes documents to each of its Outputs
+// in turn.
+type multiOutput []gobench.Output
+
+func (m multiOutput) Write(doc gobench.Document) error {
+	for _, output := range m {
+		if err := output.Write(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiOutput) Flush() error {
+	for _, output := range m {
+		if err := output.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
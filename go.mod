@@ -1,21 +1,20 @@
 module github.com/elastic/gobench
 
-go 1.17
+go 1.21
 
 require (
 	github.com/blang/semver v3.5.1+incompatible
-	github.com/kr/pretty v0.3.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/term v0.23.0
 	golang.org/x/tools v0.24.0
-	golang.org/x/tools/go/vcs v0.1.0-deprecated
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/kr/text v0.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/rogpeppe/go-internal v1.9.0 // indirect
 	golang.org/x/sys v0.23.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// firstRolloverIndexSuffix is appended to Config.Index to name the first
+// index of a write alias, so that rolled over indices are numbered.
+const firstRolloverIndexSuffix = "-000001"
+
+// bootstrapAlias creates the write alias cfg.Alias, pointing at a first
+// index named after cfg.Index with the mappings and settings, if the alias
+// does not exist. Otherwise the mappings of its indices are updated.
+func bootstrapAlias(ctx context.Context, cfg Config, esVersion *semver.Version) error {
+	exists, err := aliasExists(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	includeTypeName := esTypeNames(esVersion).mappings
+	if exists {
+		cfg.logger().Debug("alias already exists, updating mapping", "alias", cfg.Alias)
+		aliasCfg := cfg
+		aliasCfg.Index = cfg.Alias
+		return updateMapping(ctx, aliasCfg, includeTypeName)
+	}
+
+	index := map[string]interface{}{
+		"aliases": map[string]interface{}{
+			cfg.Alias: map[string]interface{}{"is_write_index": true},
+		},
+		"mappings": esMappings(includeTypeName, cfg.MappingOverrides),
+	}
+	if settings := esIndexSettings(cfg); settings != nil {
+		index["settings"] = settings
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(index); err != nil {
+		return err
+	}
+	indexURL := cfg.URL + "/" + url.PathEscape(cfg.Index+firstRolloverIndexSuffix)
+	if cfg.WaitForActiveShards != "" {
+		indexURL += "?" + url.Values{"wait_for_active_shards": {cfg.WaitForActiveShards}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, indexURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	if err := handleResponse(resp, cfg.logger()); err != nil {
+		return errors.Wrapf(err, "error creating index %s%s for alias %s", cfg.Index, firstRolloverIndexSuffix, cfg.Alias)
+	}
+	return nil
+}
+
+// aliasExists reports whether the alias cfg.Alias exists.
+func aliasExists(ctx context.Context, cfg Config) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, cfg.URL+"/_alias/"+url.PathEscape(cfg.Alias), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := cfg.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, errors.Errorf("error checking alias %s: %s", cfg.Alias, resp.Status)
+}
+
+// rolloverConditions returns the conditions under which the alias is
+// rolled over by rolloverAlias.
+func rolloverConditions(cfg Config) map[string]interface{} {
+	conditions := make(map[string]interface{})
+	if cfg.RolloverMaxAge != "" {
+		conditions["max_age"] = cfg.RolloverMaxAge
+	}
+	if cfg.RolloverMaxDocs > 0 {
+		conditions["max_docs"] = cfg.RolloverMaxDocs
+	}
+	if cfg.RolloverMaxSize != "" {
+		conditions["max_size"] = cfg.RolloverMaxSize
+	}
+	return conditions
+}
+
+// rolloverAlias rolls over the alias cfg.Alias to a new index if any of
+// its conditions are met, logging the outcome.
+func rolloverAlias(ctx context.Context, cfg Config) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]interface{}{
+		"conditions": rolloverConditions(cfg),
+	}); err != nil {
+		return err
+	}
+	rolloverURL := cfg.URL + "/" + url.PathEscape(cfg.Alias) + "/_rollover"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rolloverURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	// Read the body so that it can be decoded after handleResponse.
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err := handleResponse(resp, cfg.logger()); err != nil {
+		return err
+	}
+	var result struct {
+		RolledOver bool   `json:"rolled_over"`
+		OldIndex   string `json:"old_index"`
+		NewIndex   string `json:"new_index"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	if result.RolledOver {
+		cfg.logger().Info("rolled over alias", "alias", cfg.Alias, "old_index", result.OldIndex, "new_index", result.NewIndex)
+	} else {
+		cfg.logger().Debug("rollover conditions not met", "alias", cfg.Alias)
+	}
+	return nil
+}
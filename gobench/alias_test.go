@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_IndexerAliasRollover(t *testing.T) {
+	var paths []string
+	var index, rollover map[string]interface{}
+	var actions []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+		case r.Method == http.MethodHead && r.URL.Path == "/_alias/gobench-write":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut && r.URL.Path == "/gobench-000001":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&index))
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			decoder := json.NewDecoder(r.Body)
+			for {
+				var action, doc map[string]interface{}
+				if err := decoder.Decode(&action); err == io.EOF {
+					break
+				}
+				require.NoError(t, decoder.Decode(&doc))
+				actions = append(actions, action)
+			}
+			w.Write([]byte(`{"took":1,"errors":false}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/gobench-write/_rollover":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&rollover))
+			w.Write([]byte(`{"acknowledged":true,"rolled_over":true,"old_index":"gobench-000001","new_index":"gobench-000002"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	indexer, err := NewIndexer(context.Background(), Config{
+		URL:             srv.URL,
+		Index:           "gobench",
+		Alias:           "gobench-write",
+		AutoRollover:    true,
+		RolloverMaxAge:  "7d",
+		RolloverMaxDocs: 1000,
+	})
+	require.NoError(t, err)
+
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	doc := NewDocument(b, "example.com/foo", "linux", "amd64", "", nil, time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, indexer.Write(doc))
+	require.NoError(t, indexer.Flush())
+	assert.Equal(t, 1, indexer.Indexed())
+
+	assert.Equal(t, []string{
+		"GET /",
+		"HEAD /_alias/gobench-write",
+		"PUT /gobench-000001",
+		"POST /_bulk",
+		"POST /gobench-write/_rollover",
+	}, paths)
+	assert.Equal(t, map[string]interface{}{
+		"gobench-write": map[string]interface{}{"is_write_index": true},
+	}, index["aliases"])
+	assert.Contains(t, index, "mappings")
+	require.Len(t, actions, 1)
+	assert.Equal(t, map[string]interface{}{"index": map[string]interface{}{"_index": "gobench-write"}}, actions[0])
+	assert.Equal(t, map[string]interface{}{
+		"conditions": map[string]interface{}{"max_age": "7d", "max_docs": 1000.0},
+	}, rollover)
+}
+
+func Test_createMappingAliasExists(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := Config{URL: srv.URL, Index: "gobench", Alias: "gobench-write"}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	assert.Equal(t, []string{
+		"HEAD /_alias/gobench-write",
+		"PUT /gobench-write/_mapping",
+	}, paths)
+}
+
+func Test_createMappingAliasTemplate(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := Config{URL: srv.URL, Index: "gobench", Alias: "gobench-write", UseTemplate: true}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	assert.Equal(t, []string{
+		"PUT /_index_template/gobench",
+		"HEAD /_alias/gobench-write",
+		"PUT /gobench-000001",
+	}, paths)
+}
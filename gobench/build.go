@@ -0,0 +1,64 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadBuildSettings returns the build settings of the named Go binary,
+// such as a test binary built with "go test -c", as reported by
+// "go version -m". These include flags such as -gcflags, -tags and -pgo,
+// which may materially affect benchmark results, keyed by flag name
+// without the leading hyphen, e.g. "gcflags", and environment settings
+// such as CGO_ENABLED and GOAMD64. VCS settings are omitted, as they are
+// recorded under FieldGit or FieldHg.
+//
+// The returned settings are intended to be stored under
+// FieldBuildSettings.
+func ReadBuildSettings(binary string) (map[string]string, error) {
+	output, err := runCommand("", "go", "version", "-m", binary)
+	if err != nil {
+		// go version reports errors, such as a missing file, on its output.
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			err = errors.New(msg)
+		}
+		return nil, errors.Wrapf(err, "error reading build settings of %s", binary)
+	}
+	return parseBuildSettings(string(output)), nil
+}
+
+// parseBuildSettings parses the "build" lines of the output of
+// "go version -m".
+func parseBuildSettings(output string) map[string]string {
+	settings := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "\t", 2)
+		if len(fields) != 2 || fields[0] != "build" {
+			continue
+		}
+		kv := strings.SplitN(fields[1], "=", 2)
+		if len(kv) != 2 || kv[0] == "vcs" || strings.HasPrefix(kv[0], "vcs.") {
+			continue
+		}
+		settings[strings.TrimPrefix(kv[0], "-")] = kv[1]
+	}
+	return settings
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goVersionOutput = `/tmp/foo.test: go1.22.1
+	path	example.com/foo.test
+	mod	example.com/foo	(devel)	
+	dep	github.com/pkg/errors	v0.9.1	h1:FEBLx1zS214owpjy7qsBeixbURkuhQAwrK5UwLGTwt4=
+	build	-buildmode=exe
+	build	-compiler=gc
+	build	-gcflags=all=-N -l
+	build	-tags=integration,netgo
+	build	-pgo=/src/foo/default.pgo
+	build	CGO_ENABLED=1
+	build	GOARCH=amd64
+	build	GOOS=linux
+	build	GOAMD64=v3
+	build	vcs=git
+	build	vcs.revision=0a1b2c3d4e5f
+	build	vcs.modified=true
+`
+
+func Test_parseBuildSettings(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"buildmode":   "exe",
+		"compiler":    "gc",
+		"gcflags":     "all=-N -l",
+		"tags":        "integration,netgo",
+		"pgo":         "/src/foo/default.pgo",
+		"CGO_ENABLED": "1",
+		"GOARCH":      "amd64",
+		"GOOS":        "linux",
+		"GOAMD64":     "v3",
+	}, parseBuildSettings(goVersionOutput))
+
+	assert.Empty(t, parseBuildSettings("/tmp/foo.test: go1.22.1\n\tpath\texample.com/foo.test\n"))
+}
+
+func Test_ReadBuildSettings(t *testing.T) {
+	stubCommands(t, map[string]string{
+		"go version -m /tmp/foo.test": goVersionOutput,
+	})
+	settings, err := ReadBuildSettings("/tmp/foo.test")
+	require.NoError(t, err)
+	assert.Equal(t, "all=-N -l", settings["gcflags"])
+
+	_, err = ReadBuildSettings("/tmp/bar.test")
+	assert.EqualError(t, err, "error reading build settings of /tmp/bar.test: go: command not found")
+}
@@ -0,0 +1,456 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// bulkWriter buffers NDJSON-encoded bulk actions, sending them to
+// Elasticsearch in requests of approximately cfg.BulkMaxBytes. If
+// cfg.Workers is greater than one and start has been called, requests are
+// sent concurrently by that many workers.
+//
+// If cfg.BulkMaxBytes is zero, the actions are instead streamed to
+// Elasticsearch in a single chunked request as they are written, so that
+// memory use is bounded regardless of the number of actions.
+type bulkWriter struct {
+	// ctx is the context for bulk requests.
+	ctx   context.Context
+	cfg   Config
+	esURL *url.URL
+	buf   bytes.Buffer
+
+	requests int
+
+	// sent counts the documents handed off in bulk requests, for
+	// reporting the positions of failed items.
+	sent int
+
+	// stream, if non-nil, is the body of the streamed bulk request
+	// described by streamReq, whose outcome is sent to streamDone.
+	stream     *io.PipeWriter
+	streamReq  bulkRequest
+	streamDone chan error
+	names      []string
+
+	// pending, if non-nil, receives the requests to be sent by the
+	// workers, which are tracked by wg.
+	pending chan bulkRequest
+	wg      sync.WaitGroup
+
+	// mu protects the fields below, which are updated as each bulk
+	// request completes.
+	mu   sync.Mutex
+	errs []bulkRequestError
+
+	// indexed and failed count the documents successfully and
+	// unsuccessfully indexed.
+	indexed int
+	failed  int
+}
+
+// bulkRequest is a bulk request body, along with its 1-based number and
+// the number of documents sent before it.
+type bulkRequest struct {
+	number int
+	offset int
+	docs   int
+	body   []byte
+}
+
+// bulkRequestError records the error from a numbered bulk request.
+type bulkRequestError struct {
+	number int
+	err    error
+}
+
+// start starts cfg.Workers workers to send bulk requests concurrently.
+// If cfg.Workers is less than two, requests are sent synchronously as
+// the buffer fills.
+func (w *bulkWriter) start() {
+	if w.cfg.Workers < 2 {
+		return
+	}
+	w.pending = make(chan bulkRequest)
+	w.wg.Add(w.cfg.Workers)
+	for i := 0; i < w.cfg.Workers; i++ {
+		go func() {
+			defer w.wg.Done()
+			for req := range w.pending {
+				w.send(req)
+			}
+		}()
+	}
+}
+
+func (w *bulkWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// flushIfFull sends the buffered actions if they have reached the
+// configured maximum bulk request size. It must only be called between
+// complete actions.
+func (w *bulkWriter) flushIfFull() {
+	if w.cfg.BulkMaxBytes == 0 {
+		w.streamBuffered()
+	} else if w.buf.Len() >= w.cfg.BulkMaxBytes {
+		w.flush()
+	}
+}
+
+// streamBuffered writes the buffered actions to the streamed bulk
+// request, starting the request if necessary.
+func (w *bulkWriter) streamBuffered() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	if w.stream == nil {
+		w.requests++
+		w.streamReq = bulkRequest{number: w.requests, offset: w.sent}
+		w.names = nil
+		pr, pw := io.Pipe()
+		w.stream = pw
+		w.streamDone = make(chan error, 1)
+		go func() {
+			err := bulkIndex(w.ctx, w.cfg, w.esURL, pr)
+			// Unblock any further writes if the request ended early.
+			pr.CloseWithError(err)
+			w.streamDone <- err
+		}()
+	}
+	docs := bytes.Count(w.buf.Bytes(), []byte{'\n'}) / 2
+	w.streamReq.docs += docs
+	w.sent += docs
+	w.names = append(w.names, bulkDocumentNames(w.buf.Bytes())...)
+	// If the request has failed, the error is reported by endStream.
+	w.buf.WriteTo(w.stream)
+	w.buf.Reset()
+}
+
+// endStream ends the streamed bulk request, if any, and records its
+// outcome.
+func (w *bulkWriter) endStream() {
+	if w.stream == nil {
+		return
+	}
+	w.stream.Close()
+	err := <-w.streamDone
+	w.record(w.streamReq, w.names, err)
+	w.stream = nil
+}
+
+// flush sends the buffered actions, or hands them off to a worker,
+// recording any error so that the remaining actions can still be sent.
+func (w *bulkWriter) flush() {
+	if w.buf.Len() == 0 {
+		return
+	}
+	w.requests++
+	req := bulkRequest{
+		number: w.requests,
+		offset: w.sent,
+		// Each document is preceded by an action line.
+		docs: bytes.Count(w.buf.Bytes(), []byte{'\n'}) / 2,
+		body: w.buf.Bytes(),
+	}
+	w.sent += req.docs
+	if w.pending != nil {
+		req.body = append([]byte(nil), req.body...)
+		w.buf.Reset()
+		w.pending <- req
+		return
+	}
+	w.send(req)
+	w.buf.Reset()
+}
+
+// send sends a bulk request, and records its outcome.
+func (w *bulkWriter) send(req bulkRequest) {
+	err := bulkIndex(w.ctx, w.cfg, w.esURL, bytes.NewReader(req.body))
+	w.record(req, bulkDocumentNames(req.body), err)
+}
+
+// record records the outcome of a bulk request, given the names of its
+// documents.
+func (w *bulkWriter) record(req bulkRequest, names []string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		failed := req.docs
+		if itemsErr, ok := err.(*bulkItemsError); ok {
+			itemsErr.names = names
+			itemsErr.offset = req.offset
+			failed = len(itemsErr.failed)
+		}
+		w.errs = append(w.errs, bulkRequestError{
+			number: req.number,
+			err:    errors.Wrapf(err, "bulk request %d", req.number),
+		})
+		w.failed += failed
+		w.indexed += req.docs - failed
+	} else {
+		w.indexed += req.docs
+	}
+}
+
+// counts returns the number of documents successfully and unsuccessfully
+// indexed so far.
+func (w *bulkWriter) counts() (indexed, failed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.indexed, w.failed
+}
+
+// bulkDocumentNames returns the full benchmark names of the documents in
+// a bulk request body, in order. Documents without a name, or which cannot
+// be decoded, have an empty name.
+func bulkDocumentNames(body []byte) []string {
+	lines := bytes.Split(bytes.TrimRight(body, "\n"), []byte{'\n'})
+	var names []string
+	for i := 1; i < len(lines); i += 2 {
+		var doc struct {
+			Name     string `json:"name"`
+			FullName string `json:"full_name"`
+		}
+		json.Unmarshal(lines[i], &doc)
+		if doc.FullName != "" {
+			doc.Name = doc.FullName
+		}
+		names = append(names, doc.Name)
+	}
+	return names
+}
+
+// drain sends any remaining actions, and waits for any workers to finish
+// sending their requests. The workers must be restarted with start before
+// further actions are written.
+func (w *bulkWriter) drain() {
+	if w.cfg.BulkMaxBytes == 0 {
+		w.streamBuffered()
+		w.endStream()
+	} else {
+		w.flush()
+	}
+	if w.pending != nil {
+		close(w.pending)
+		w.wg.Wait()
+		w.pending = nil
+	}
+}
+
+// close flushes any remaining actions, waits for any workers to finish,
+// and returns an error describing all of the bulk requests that failed.
+func (w *bulkWriter) close() error {
+	w.drain()
+	// Workers may complete requests out of order.
+	sort.Slice(w.errs, func(i, j int) bool {
+		return w.errs[i].number < w.errs[j].number
+	})
+	switch len(w.errs) {
+	case 0:
+		return nil
+	case 1:
+		return w.errs[0].err
+	}
+	msgs := make([]string, len(w.errs))
+	for i, err := range w.errs {
+		msgs[i] = err.err.Error()
+	}
+	return errors.Errorf(
+		"%d of %d bulk requests failed: %s",
+		len(w.errs), w.requests, strings.Join(msgs, "; "),
+	)
+}
+
+// uploadBulkFile writes the bulk actions in the named NDJSON file to bulk,
+// as by readBulkFile.
+func uploadBulkFile(path string, bulk *bulkWriter) error {
+	return readBulkFile(path, bulk.cfg.SortByTime, func(pair []byte) {
+		bulk.Write(pair)
+		bulk.flushIfFull()
+	})
+}
+
+// CopyBulkFile writes the bulk actions in the named NDJSON file to w, as
+// they would be uploaded by Indexer.UploadFile with cfg.
+func CopyBulkFile(w io.Writer, path string, cfg Config) error {
+	var err error
+	readErr := readBulkFile(path, cfg.SortByTime, func(pair []byte) {
+		if err == nil {
+			_, err = w.Write(pair)
+		}
+	})
+	if readErr != nil {
+		return readErr
+	}
+	return err
+}
+
+// readBulkFile calls f with each pair of action and document lines in the
+// named NDJSON file, as written by EncodeBulkAction. If sortByTime is set,
+// the pairs are read into memory and stably sorted by the executed_at
+// field of their documents first.
+func readBulkFile(path string, sortByTime bool, f func(pair []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var pairs []bulkPair
+	r := bufio.NewReader(file)
+	var action []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			if action == nil {
+				action = line
+			} else {
+				pair := append(action, line...)
+				action = nil
+				if !sortByTime {
+					f(pair)
+				} else {
+					var doc struct {
+						ExecutedAt time.Time `json:"executed_at"`
+					}
+					if err := json.Unmarshal(line, &doc); err != nil {
+						return errors.Wrapf(err, "%s: error reading executed_at", path)
+					}
+					pairs = append(pairs, bulkPair{executedAt: doc.ExecutedAt, data: pair})
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if action != nil {
+		return errors.Errorf("%s: missing document for final action", path)
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].executedAt.Before(pairs[j].executedAt)
+	})
+	for _, pair := range pairs {
+		f(pair.data)
+	}
+	return nil
+}
+
+// bulkPair is a pair of action and document lines buffered by
+// readBulkFile, with the execution time of the document.
+type bulkPair struct {
+	executedAt time.Time
+	data       []byte
+}
+
+// RefreshValues holds the valid values of the -refresh flag.
+var RefreshValues = []string{"false", "true", "wait_for"}
+
+// IsRefreshValue reports whether refresh is one of RefreshValues.
+func IsRefreshValue(refresh string) bool {
+	for _, v := range RefreshValues {
+		if v == refresh {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkIndex sends the NDJSON-encoded actions in body to the _bulk endpoint.
+// If body is a *bytes.Reader, it is sent with a Content-Length and may be
+// retried; otherwise it is streamed with chunked transfer encoding.
+func bulkIndex(ctx context.Context, cfg Config, esURL *url.URL, body io.Reader) error {
+	bulkURL := *esURL
+	bulkURL.Path += "/_bulk"
+	query := bulkURL.Query()
+	if cfg.Refresh != "" && cfg.Refresh != "false" {
+		query.Set("refresh", cfg.Refresh)
+	}
+	if cfg.Pipeline != "" {
+		query.Set("pipeline", cfg.Pipeline)
+	}
+	if cfg.WaitForActiveShards != "" && usesTemplate(cfg) {
+		// Indices are created by the bulk request itself.
+		query.Set("wait_for_active_shards", cfg.WaitForActiveShards)
+	}
+	bulkURL.RawQuery = query.Encode()
+	if cfg.Compress {
+		if _, ok := body.(*bytes.Reader); ok {
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			if _, err := io.Copy(zw, body); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			body = &compressed
+		} else {
+			body = gzipStream(body)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bulkURL.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if cfg.Compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	return handleResponse(resp, cfg.logger())
+}
+
+// gzipStream returns a reader of the gzip-compressed contents of r, which
+// are compressed as they are read.
+func gzipStream(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,365 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_bulkIndex(t *testing.T) {
+	newServer := func(t *testing.T, response string) *url.URL {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/_bulk", r.URL.Path)
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			w.Write([]byte(response))
+		}))
+		t.Cleanup(srv.Close)
+		u, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		return u
+	}
+	body := "{\"index\":{\"_index\":\"gobench\"}}\n{\"name\":\"BenchmarkFoo\"}\n"
+	t.Run("success", func(t *testing.T) {
+		u := newServer(t, `{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`)
+		err := bulkIndex(context.Background(), Config{}, u, strings.NewReader(body))
+		assert.NoError(t, err)
+	})
+	t.Run("compress", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			decompressed, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(decompressed))
+			w.Write([]byte(`{"took":1,"errors":false,"items":[{"index":{"status":201}}]}`))
+		}))
+		t.Cleanup(srv.Close)
+		u, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		err = bulkIndex(context.Background(), Config{Compress: true}, u, strings.NewReader(body))
+		assert.NoError(t, err)
+	})
+	t.Run("item-errors", func(t *testing.T) {
+		u := newServer(t, `{"took":1,"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`)
+		err := bulkIndex(context.Background(), Config{}, u, strings.NewReader(body))
+		assert.EqualError(t, err, "1 of 1 bulk items failed: item 1: status 400: mapper_parsing_exception: failed to parse")
+	})
+}
+
+func Test_bulkIndexRefresh(t *testing.T) {
+	var rawQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+		w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	for refresh, expected := range map[string]string{
+		"":         "",
+		"false":    "",
+		"true":     "refresh=true",
+		"wait_for": "refresh=wait_for",
+	} {
+		err := bulkIndex(context.Background(), Config{Refresh: refresh}, u, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, rawQuery, refresh)
+	}
+
+	t.Run("pipeline", func(t *testing.T) {
+		err := bulkIndex(context.Background(), Config{Pipeline: "geoip"}, u, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "pipeline=geoip", rawQuery)
+
+		cfg := Config{Pipeline: "geoip", Refresh: "wait_for"}
+		err = bulkIndex(context.Background(), cfg, u, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "pipeline=geoip&refresh=wait_for", rawQuery)
+	})
+
+	t.Run("wait_for_active_shards", func(t *testing.T) {
+		// The index is created, and its shards waited for, up front.
+		cfg := Config{Index: "gobench", WaitForActiveShards: "2"}
+		err := bulkIndex(context.Background(), cfg, u, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "", rawQuery)
+
+		cfg.UseTemplate = true
+		err = bulkIndex(context.Background(), cfg, u, strings.NewReader("{}\n{}\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "wait_for_active_shards=2", rawQuery)
+	})
+}
+
+func Test_bulkWriter(t *testing.T) {
+	var mu sync.Mutex
+	var requestLines []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines int
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines++
+		}
+		mu.Lock()
+		requestLines = append(requestLines, lines)
+		mu.Unlock()
+		w.Write([]byte(`{"took":1,"errors":false}`))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	cfg := Config{Index: "gobench", BulkMaxBytes: 1024}
+	bulk := &bulkWriter{ctx: context.Background(), cfg: cfg, esURL: u}
+	encoder := json.NewEncoder(bulk)
+	const numBenchmarks = 20
+	for i := 0; i < numBenchmarks; i++ {
+		b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo", N: i + 1, NsPerOp: 1, Measured: parse.NsPerOp}}
+		doc := NewDocument(b, "", "linux", "amd64", "", nil, time.Now())
+		require.NoError(t, EncodeBulkAction(encoder, doc, cfg, nil))
+		bulk.flushIfFull()
+	}
+	require.NoError(t, bulk.close())
+
+	assert.GreaterOrEqual(t, len(requestLines), 2)
+	var total int
+	for _, lines := range requestLines {
+		assert.Zero(t, lines%2, "each request should contain whole action/document pairs")
+		total += lines
+	}
+	assert.Equal(t, numBenchmarks*2, total)
+}
+
+func Test_bulkWriterItemErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"took":1,"errors":true,"items":[
+			{"index":{"status":201}},
+			{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [ns_per_op]"}}},
+			{"index":{"status":201}}
+		]}`))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	bulk := &bulkWriter{ctx: context.Background(), esURL: u}
+	for _, name := range []string{"BenchmarkA", "BenchmarkB/size=1", "BenchmarkC"} {
+		fmt.Fprintf(bulk, "{\"index\":{}}\n{\"name\":%q}\n", name)
+	}
+	assert.EqualError(t, bulk.close(),
+		"bulk request 1: 1 of 3 bulk items failed: item 2 (BenchmarkB/size=1): "+
+			"status 400: mapper_parsing_exception: failed to parse field [ns_per_op]",
+	)
+	assert.Equal(t, 2, bulk.indexed)
+	assert.Equal(t, 1, bulk.failed)
+}
+
+func Test_bulkWriterErrors(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error":{"type":"content_too_long","reason":"too large"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	bulk := &bulkWriter{ctx: context.Background(), cfg: Config{BulkMaxBytes: 1}, esURL: u}
+	for i := 0; i < 2; i++ {
+		io.WriteString(bulk, "{}\n{}\n")
+		bulk.flushIfFull()
+	}
+	assert.EqualError(t, bulk.close(), "2 of 2 bulk requests failed: bulk request 1: too large; bulk request 2: too large")
+	assert.Equal(t, 2, requests)
+}
+
+func Test_bulkWriterWorkers(t *testing.T) {
+	const workers = 4
+	var mu sync.Mutex
+	var requests, docs, inFlight int
+	concurrent := make(chan struct{})
+	var closeConcurrent sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		requests++
+		docs += bytes.Count(body, []byte{'\n'}) / 2
+		inFlight++
+		if inFlight > 1 {
+			closeConcurrent.Do(func() { close(concurrent) })
+		}
+		mu.Unlock()
+
+		// Hold each request open until another arrives, to show that
+		// requests are sent concurrently.
+		select {
+		case <-concurrent:
+		case <-time.After(5 * time.Second):
+			t.Error("timed out waiting for concurrent requests")
+		}
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`{"took":1,"errors":false}`))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	bulk := &bulkWriter{ctx: context.Background(), cfg: Config{BulkMaxBytes: 1, Workers: workers}, esURL: u}
+	bulk.start()
+	const numDocs = 20
+	for i := 0; i < numDocs; i++ {
+		fmt.Fprintf(bulk, "{\"index\":{}}\n{\"name\":\"Benchmark%d\"}\n", i)
+		bulk.flushIfFull()
+	}
+	require.NoError(t, bulk.close())
+	assert.Equal(t, numDocs, requests)
+	assert.Equal(t, numDocs, docs)
+	indexed, failed := bulk.counts()
+	assert.Equal(t, numDocs, indexed)
+	assert.Equal(t, 0, failed)
+}
+
+func Test_bulkWriterWorkersErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error":{"type":"content_too_long","reason":"too large"}}`))
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	bulk := &bulkWriter{ctx: context.Background(), cfg: Config{BulkMaxBytes: 1, Workers: 3}, esURL: u}
+	bulk.start()
+	for i := 0; i < 3; i++ {
+		io.WriteString(bulk, "{}\n{}\n")
+		bulk.flushIfFull()
+	}
+	// Errors are reported in request order, however the workers
+	// complete them.
+	assert.EqualError(t, bulk.close(),
+		"3 of 3 bulk requests failed: bulk request 1: too large; bulk request 2: too large; bulk request 3: too large",
+	)
+	indexed, failed := bulk.counts()
+	assert.Equal(t, 0, indexed)
+	assert.Equal(t, 3, failed)
+}
+
+func Test_bulkWriterStream(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%t", compress), func(t *testing.T) {
+			var docs, requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+				body := io.Reader(r.Body)
+				if compress {
+					assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+					zr, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					body = zr
+				}
+				scanner := bufio.NewScanner(body)
+				for scanner.Scan() {
+					docs++
+				}
+				require.NoError(t, scanner.Err())
+				docs /= 2
+				w.Write([]byte(`{"took":1,"errors":false}`))
+			}))
+			t.Cleanup(srv.Close)
+			u, err := url.Parse(srv.URL)
+			require.NoError(t, err)
+
+			bulk := &bulkWriter{ctx: context.Background(), cfg: Config{Compress: compress}, esURL: u}
+			const numDocs = 10000
+			for i := 0; i < numDocs; i++ {
+				fmt.Fprintf(bulk, "{\"index\":{}}\n{\"name\":\"Benchmark%d\"}\n", i)
+				bulk.flushIfFull()
+				// Only the current action is buffered.
+				assert.Zero(t, bulk.buf.Len())
+			}
+			require.NoError(t, bulk.close())
+			assert.Equal(t, 1, requests)
+			assert.Equal(t, numDocs, docs)
+			indexed, failed := bulk.counts()
+			assert.Equal(t, numDocs, indexed)
+			assert.Equal(t, 0, failed)
+		})
+	}
+}
+
+func Test_CopyBulkFileSortByTime(t *testing.T) {
+	const shuffled = `{"index":{"_index":"gobench"}}
+{"name":"BenchmarkC","executed_at":"2024-01-15T12:00:00Z"}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkA","executed_at":"2024-01-13T10:00:00Z"}
+
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkB1","executed_at":"2024-01-14T10:00:00+02:00"}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkB2","executed_at":"2024-01-14T08:00:00Z"}`
+	path := filepath.Join(t.TempDir(), "bulk.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(shuffled), 0644))
+
+	var out bytes.Buffer
+	require.NoError(t, CopyBulkFile(&out, path, Config{SortByTime: true}))
+	assert.Equal(t, `{"index":{"_index":"gobench"}}
+{"name":"BenchmarkA","executed_at":"2024-01-13T10:00:00Z"}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkB1","executed_at":"2024-01-14T10:00:00+02:00"}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkB2","executed_at":"2024-01-14T08:00:00Z"}
+{"index":{"_index":"gobench"}}
+{"name":"BenchmarkC","executed_at":"2024-01-15T12:00:00Z"}
+`, out.String())
+
+	// Without sorting, the file is copied in order.
+	out.Reset()
+	require.NoError(t, CopyBulkFile(&out, path, Config{}))
+	assert.Equal(t, 4, strings.Count(out.String(), `{"index"`))
+	assert.Less(t, strings.Index(out.String(), "BenchmarkC"), strings.Index(out.String(), "BenchmarkA"))
+
+	require.NoError(t, os.WriteFile(path, []byte(shuffled+"\n{\"index\":{}}\n"), 0644))
+	err := CopyBulkFile(&out, path, Config{SortByTime: true})
+	assert.EqualError(t, err, path+": missing document for final action")
+}
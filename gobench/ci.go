@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"os"
+	"strings"
+)
+
+// ciProvider describes how to detect a CI system, and the environment
+// variables from which its build metadata is obtained.
+type ciProvider struct {
+	name string
+
+	// detect is an environment variable which is set to "true" when
+	// running in the CI system.
+	detect string
+
+	// fields maps ci sub-fields to the environment variables holding
+	// their values.
+	fields map[string]string
+
+	// extra optionally computes fields which cannot be read directly
+	// from a single environment variable.
+	extra func(fields map[string]interface{})
+}
+
+var ciProviders = []ciProvider{{
+	name:   "github_actions",
+	detect: "GITHUB_ACTIONS",
+	fields: map[string]string{
+		FieldCIBuildID:    "GITHUB_RUN_ID",
+		FieldCICommit:     "GITHUB_SHA",
+		FieldCIBranch:     "GITHUB_REF_NAME",
+		FieldCIRepository: "GITHUB_REPOSITORY",
+	},
+	extra: func(fields map[string]interface{}) {
+		server, repo, runID := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+		if server != "" && repo != "" && runID != "" {
+			fields[FieldCIJobURL] = server + "/" + repo + "/actions/runs/" + runID
+		}
+		// For pull requests, GITHUB_REF is "refs/pull/<number>/merge".
+		ref := os.Getenv("GITHUB_REF")
+		if strings.HasPrefix(ref, "refs/pull/") {
+			if number := strings.Split(ref, "/")[2]; number != "" {
+				fields[FieldCIPullRequest] = number
+			}
+		}
+	},
+}, {
+	name:   "gitlab",
+	detect: "GITLAB_CI",
+	fields: map[string]string{
+		FieldCIBuildID:     "CI_PIPELINE_ID",
+		FieldCIJobURL:      "CI_JOB_URL",
+		FieldCICommit:      "CI_COMMIT_SHA",
+		FieldCIBranch:      "CI_COMMIT_REF_NAME",
+		FieldCIRepository:  "CI_PROJECT_PATH",
+		FieldCIPullRequest: "CI_MERGE_REQUEST_IID",
+	},
+}, {
+	name:   "buildkite",
+	detect: "BUILDKITE",
+	fields: map[string]string{
+		FieldCIBuildID:    "BUILDKITE_BUILD_NUMBER",
+		FieldCIJobURL:     "BUILDKITE_BUILD_URL",
+		FieldCICommit:     "BUILDKITE_COMMIT",
+		FieldCIBranch:     "BUILDKITE_BRANCH",
+		FieldCIRepository: "BUILDKITE_REPO",
+	},
+	extra: func(fields map[string]interface{}) {
+		// BUILDKITE_PULL_REQUEST is "false" for non-PR builds.
+		if pr := os.Getenv("BUILDKITE_PULL_REQUEST"); pr != "" && pr != "false" {
+			fields[FieldCIPullRequest] = pr
+		}
+	},
+}}
+
+// addCI adds metadata about the CI build to doc, if running in one of
+// the supported CI systems.
+func addCI(doc map[string]interface{}) {
+	for _, provider := range ciProviders {
+		if os.Getenv(provider.detect) != "true" {
+			continue
+		}
+		fields := map[string]interface{}{FieldCIProvider: provider.name}
+		for field, key := range provider.fields {
+			if value := os.Getenv(key); value != "" {
+				fields[field] = value
+			}
+		}
+		if provider.extra != nil {
+			provider.extra(fields)
+		}
+		doc[FieldCI] = fields
+		return
+	}
+}
@@ -0,0 +1,62 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearCIEnv unsets the environment variables used to detect each CI
+// provider for the duration of the test.
+func clearCIEnv(t *testing.T) {
+	for _, provider := range ciProviders {
+		t.Setenv(provider.detect, "")
+	}
+}
+
+func Test_addCIGitHubActions(t *testing.T) {
+	clearCIEnv(t)
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_REPOSITORY", "elastic/gobench")
+	t.Setenv("GITHUB_RUN_ID", "1234567890")
+	t.Setenv("GITHUB_SHA", "0123456789abcdef")
+	t.Setenv("GITHUB_REF", "refs/pull/42/merge")
+	t.Setenv("GITHUB_REF_NAME", "42/merge")
+
+	doc := make(map[string]interface{})
+	addCI(doc)
+	assert.Equal(t, map[string]interface{}{
+		FieldCIProvider:    "github_actions",
+		FieldCIBuildID:     "1234567890",
+		FieldCIJobURL:      "https://github.com/elastic/gobench/actions/runs/1234567890",
+		FieldCICommit:      "0123456789abcdef",
+		FieldCIBranch:      "42/merge",
+		FieldCIRepository:  "elastic/gobench",
+		FieldCIPullRequest: "42",
+	}, doc[FieldCI])
+}
+
+func Test_addCINone(t *testing.T) {
+	clearCIEnv(t)
+	doc := make(map[string]interface{})
+	addCI(doc)
+	assert.NotContains(t, doc, FieldCI)
+}
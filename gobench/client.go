@@ -0,0 +1,208 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewHTTPClient returns an HTTP client for talking to Elasticsearch,
+// configured with the proxy, connection pool, timeout and TLS settings in
+// cfg. The client has no overall Timeout, which would also interrupt
+// reading large response bodies; each phase of a request is limited
+// instead, as described for Config.DialTimeout.
+func NewHTTPClient(cfg Config) (*http.Client, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTransport returns the transport used by NewHTTPClient. If cfg.Proxy
+// is empty, the proxy is taken from the environment, and any zero-valued
+// connection pool settings are those of http.DefaultTransport.
+func newTransport(cfg Config) (*http.Transport, error) {
+	if cfg.CACert != "" && cfg.Insecure {
+		return nil, errors.New("-es-ca-cert and -es-insecure are mutually exclusive")
+	}
+	if (cfg.ClientCert == "") != (cfg.ClientKey == "") {
+		return nil, errors.New("-es-client-cert and -es-client-key must be specified together")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, errors.Errorf("invalid proxy URL %q: %s", cfg.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.CACert == "" && !cfg.Insecure && cfg.ClientCert == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading CA certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// retryBaseDelay is the delay before the first retry of a failed request.
+// Subsequent retries back off exponentially, with jitter.
+var retryBaseDelay = 500 * time.Millisecond
+
+// do sends req to Elasticsearch using the configured client and
+// authentication, retrying transient failures up to cfg.MaxRetries times.
+// Each retry resends the full body, as returned by req.GetBody; requests
+// streamed with a body of unknown length cannot be retried.
+func (cfg Config) do(req *http.Request) (*http.Response, error) {
+	setAuth(req, cfg)
+	if cfg.MaxRetries > 0 {
+		if err := bufferBody(req); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Trace {
+		req = traceRequest(req, cfg.logger())
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if attempt >= cfg.MaxRetries || req.Context().Err() != nil || !isRetryable(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// The body has been consumed and cannot be resent.
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := retryBaseDelay << uint(attempt)
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		if err == nil {
+			err = errors.New(resp.Status)
+		}
+		cfg.logger().Debug("retrying failed request",
+			"method", req.Method,
+			"url", req.URL.Redacted(),
+			"error", err,
+			"delay", delay,
+		)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// bufferBody sets req.GetBody, if http.NewRequest did not, for a request
+// whose body has a known length, by reading the body into memory so that
+// it can be resent. Bodies of unknown length are left to be streamed.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil || req.ContentLength <= 0 {
+		return nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// isRetryable reports whether a request that resulted in resp or err
+// may succeed if retried.
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,401 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTLSServer(t *testing.T) (*httptest.Server, string) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caCert, pemBytes, 0644))
+	return srv, caCert
+}
+
+func Test_newHTTPClient(t *testing.T) {
+	srv, caCert := newTLSServer(t)
+
+	t.Run("ca-cert", func(t *testing.T) {
+		cfg := Config{URL: srv.URL, CACert: caCert}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		v, err := getEsVersion(context.Background(), cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "8.1.0", v.String())
+	})
+	t.Run("untrusted", func(t *testing.T) {
+		_, err := getEsVersion(context.Background(), Config{URL: srv.URL})
+		assert.Error(t, err)
+	})
+	t.Run("insecure", func(t *testing.T) {
+		cfg := Config{URL: srv.URL, Insecure: true}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		_, err = getEsVersion(context.Background(), cfg)
+		assert.NoError(t, err)
+	})
+	t.Run("mutually-exclusive", func(t *testing.T) {
+		_, err := NewHTTPClient(Config{CACert: caCert, Insecure: true})
+		assert.EqualError(t, err, "-es-ca-cert and -es-insecure are mutually exclusive")
+	})
+	t.Run("invalid-ca-cert", func(t *testing.T) {
+		invalid := filepath.Join(t.TempDir(), "invalid.pem")
+		require.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0644))
+		_, err := NewHTTPClient(Config{CACert: invalid})
+		assert.EqualError(t, err, "no certificates found in "+invalid)
+	})
+}
+
+func Test_newHTTPClientProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.Method+" "+r.Host)
+		mu.Unlock()
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	t.Run("http", func(t *testing.T) {
+		cfg := Config{URL: "http://elasticsearch.invalid:9200", Proxy: proxy.URL}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		v, err := getEsVersion(context.Background(), cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "8.1.0", v.String())
+	})
+	t.Run("https", func(t *testing.T) {
+		// The proxy is used along with custom TLS settings.
+		cfg := Config{URL: "https://elasticsearch.invalid:9200", Proxy: proxy.URL, Insecure: true}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		_, err = getEsVersion(context.Background(), cfg)
+		assert.Error(t, err)
+	})
+	assert.Equal(t, []string{
+		"GET elasticsearch.invalid:9200",
+		"CONNECT elasticsearch.invalid:9200",
+	}, proxied)
+
+	_, err := NewHTTPClient(Config{Proxy: "http://proxy:port"})
+	assert.Error(t, err)
+}
+
+func Test_newTransportConnectionPool(t *testing.T) {
+	transport, err := newTransport(Config{})
+	require.NoError(t, err)
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, defaultTransport.MaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, defaultTransport.IdleConnTimeout, transport.IdleConnTimeout)
+
+	transport, err = newTransport(Config{MaxIdleConnsPerHost: 16, IdleConnTimeout: 5 * time.Minute, Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Minute, transport.IdleConnTimeout)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+}
+
+func Test_newTransportTimeouts(t *testing.T) {
+	transport, err := newTransport(Config{TLSHandshakeTimeout: 5 * time.Second, ResponseHeaderTimeout: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, time.Minute, transport.ResponseHeaderTimeout)
+}
+
+func Test_newHTTPClientSlowBody(t *testing.T) {
+	// The server responds promptly, but takes longer than the response
+	// header timeout to write the body, or hangs before responding.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < 5; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	const timeout = 100 * time.Millisecond
+	client, err := NewHTTPClient(Config{ResponseHeaderTimeout: timeout})
+	require.NoError(t, err)
+	assert.Zero(t, client.Timeout)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, 5, bytes.Count(body, []byte("chunk")))
+
+	_, err = client.Get(srv.URL + "/hang")
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+
+	// A blanket client timeout interrupts reading the slow body.
+	blanket := &http.Client{Transport: client.Transport, Timeout: timeout}
+	resp, err = blanket.Get(srv.URL)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Error(t, err)
+}
+
+// newClientCert generates a self-signed client certificate, writing the
+// certificate and key to PEM files in a temporary directory.
+func newClientCert(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gobench"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return cert, certFile, keyFile
+}
+
+func Test_newHTTPClientMutualTLS(t *testing.T) {
+	cert, certFile, keyFile := newClientCert(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caCert, pemBytes, 0644))
+
+	t.Run("success", func(t *testing.T) {
+		cfg := Config{URL: srv.URL, CACert: caCert, ClientCert: certFile, ClientKey: keyFile}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		_, err = getEsVersion(context.Background(), cfg)
+		assert.NoError(t, err)
+	})
+	t.Run("no-client-cert", func(t *testing.T) {
+		cfg := Config{URL: srv.URL, CACert: caCert}
+		client, err := NewHTTPClient(cfg)
+		require.NoError(t, err)
+		cfg.Client = client
+		_, err = getEsVersion(context.Background(), cfg)
+		assert.Error(t, err)
+	})
+	t.Run("cert-without-key", func(t *testing.T) {
+		_, err := NewHTTPClient(Config{ClientCert: certFile})
+		assert.EqualError(t, err, "-es-client-cert and -es-client-key must be specified together")
+	})
+	t.Run("key-without-cert", func(t *testing.T) {
+		_, err := NewHTTPClient(Config{ClientKey: keyFile})
+		assert.EqualError(t, err, "-es-client-cert and -es-client-key must be specified together")
+	})
+}
+
+func Test_doRetry(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	newServer := func(t *testing.T, statuses ...int) (*httptest.Server, *int) {
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "{}", string(body))
+			status := http.StatusOK
+			if requests < len(statuses) {
+				status = statuses[requests]
+			}
+			requests++
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &requests
+	}
+	do := func(t *testing.T, srv *httptest.Server, maxRetries int) *http.Response {
+		req, err := http.NewRequest(http.MethodPut, srv.URL, bytes.NewBufferString("{}"))
+		require.NoError(t, err)
+		resp, err := Config{MaxRetries: maxRetries}.do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("success-after-retries", func(t *testing.T) {
+		srv, requests := newServer(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		resp := do(t, srv, 3)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 3, *requests)
+	})
+	t.Run("retries-exhausted", func(t *testing.T) {
+		srv, requests := newServer(t, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests)
+		resp := do(t, srv, 2)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, 3, *requests)
+	})
+	t.Run("non-retryable", func(t *testing.T) {
+		srv, requests := newServer(t, http.StatusBadRequest)
+		resp := do(t, srv, 3)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, 1, *requests)
+	})
+}
+
+func Test_doRetryResendsBody(t *testing.T) {
+	defer func(delay time.Duration) { retryBaseDelay = delay }(retryBaseDelay)
+	retryBaseDelay = time.Millisecond
+
+	// The server reads only part of the body of the first attempt before
+	// failing it, and records the body of each later attempt.
+	newServer := func(t *testing.T) (*httptest.Server, *[][]byte) {
+		var bodies [][]byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(bodies) == 0 {
+				bodies = append(bodies, nil)
+				io.CopyN(io.Discard, r.Body, 10)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, body)
+			w.Write([]byte(`{"took":1,"errors":false}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &bodies
+	}
+	payload := bytes.Repeat([]byte(`{"index":{"_index":"gobench"}}`+"\n"+`{"name":"BenchmarkFoo"}`+"\n"), 1000)
+
+	t.Run("bulk", func(t *testing.T) {
+		srv, bodies := newServer(t)
+		esURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		cfg := Config{MaxRetries: 1}
+		require.NoError(t, bulkIndex(context.Background(), cfg, esURL, bytes.NewReader(payload)))
+		require.Len(t, *bodies, 2)
+		assert.Equal(t, payload, (*bodies)[1])
+	})
+	t.Run("bulk-compressed", func(t *testing.T) {
+		srv, bodies := newServer(t)
+		esURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		cfg := Config{MaxRetries: 1, Compress: true}
+		require.NoError(t, bulkIndex(context.Background(), cfg, esURL, bytes.NewReader(payload)))
+		require.Len(t, *bodies, 2)
+		zr, err := gzip.NewReader(bytes.NewReader((*bodies)[1]))
+		require.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, payload, body)
+	})
+	t.Run("known-length", func(t *testing.T) {
+		// A body which http.NewRequest cannot rewind is buffered.
+		srv, bodies := newServer(t)
+		req, err := http.NewRequest(http.MethodPut, srv.URL, io.MultiReader(bytes.NewReader(payload)))
+		require.NoError(t, err)
+		req.ContentLength = int64(len(payload))
+		resp, err := Config{MaxRetries: 1}.do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, *bodies, 2)
+		assert.Equal(t, payload, (*bodies)[1])
+	})
+}
+
+func Test_doContextCanceled(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := getEsVersion(ctx, Config{URL: srv.URL, MaxRetries: 3})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the canceled request to return")
+	}
+}
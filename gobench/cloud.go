@@ -0,0 +1,205 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// cloudMetadataTimeout limits the time spent querying the cloud metadata
+// endpoints, which are unreachable when not running on a cloud VM.
+var cloudMetadataTimeout = 300 * time.Millisecond
+
+// cloudProvider describes how to read the instance metadata of a cloud
+// provider from its metadata endpoint.
+type cloudProvider struct {
+	name string
+
+	// url is the base URL of the metadata endpoint, which may be
+	// replaced in tests.
+	url string
+
+	// read queries the endpoint at url using client, returning the
+	// cloud sub-fields.
+	read func(ctx context.Context, client *http.Client, url string) (map[string]interface{}, error)
+}
+
+var cloudProviders = []cloudProvider{{
+	name: "aws",
+	url:  "http://169.254.169.254",
+	read: readAWSMetadata,
+}, {
+	name: "gcp",
+	url:  "http://metadata.google.internal",
+	read: readGCPMetadata,
+}, {
+	name: "azure",
+	url:  "http://169.254.169.254",
+	read: readAzureMetadata,
+}}
+
+// addCloud adds details of the cloud instance to doc, if running on a
+// VM of one of cloudProviders. The endpoints are queried concurrently,
+// and any which do not respond within cloudMetadataTimeout are skipped.
+func addCloud(providers []cloudProvider, doc map[string]interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), cloudMetadataTimeout)
+	defer cancel()
+	// The endpoints are link-local, so requests must not use a proxy.
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+
+	results := make([]chan map[string]interface{}, len(providers))
+	for i, provider := range providers {
+		results[i] = make(chan map[string]interface{}, 1)
+		go func(provider cloudProvider, result chan<- map[string]interface{}) {
+			fields, err := provider.read(ctx, client, provider.url)
+			if err != nil || len(fields) == 0 {
+				result <- nil
+				return
+			}
+			fields[FieldCloudProvider] = provider.name
+			result <- fields
+		}(provider, results[i])
+	}
+	// Prefer the providers in order, since AWS and Azure share an
+	// address.
+	for _, result := range results {
+		if fields := <-result; fields != nil {
+			doc[FieldCloud] = fields
+			return
+		}
+	}
+}
+
+// getMetadata sends a request for the metadata at url with the given
+// headers, returning the response body if the status is 200.
+func getMetadata(ctx context.Context, client *http.Client, method, url string, header map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// readAWSMetadata reads the EC2 instance identity document, using an
+// IMDSv2 session token if one can be obtained.
+func readAWSMetadata(ctx context.Context, client *http.Client, url string) (map[string]interface{}, error) {
+	header := make(map[string]string)
+	token, err := getMetadata(ctx, client, http.MethodPut, url+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err == nil {
+		header["X-aws-ec2-metadata-token"] = string(token)
+	} else if ctx.Err() != nil {
+		return nil, err
+	}
+	data, err := getMetadata(ctx, client, http.MethodGet, url+"/latest/dynamic/instance-identity/document", header)
+	if err != nil {
+		return nil, err
+	}
+	var identity struct {
+		InstanceType     string `json:"instanceType"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, err
+	}
+	return cloudFields(identity.InstanceType, identity.Region, identity.AvailabilityZone), nil
+}
+
+// readGCPMetadata reads the Compute Engine instance metadata.
+func readGCPMetadata(ctx context.Context, client *http.Client, url string) (map[string]interface{}, error) {
+	data, err := getMetadata(ctx, client, http.MethodGet, url+"/computeMetadata/v1/instance/?recursive=true", map[string]string{
+		"Metadata-Flavor": "Google",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var instance struct {
+		// MachineType and Zone are resource paths, e.g.
+		// "projects/123/zones/us-central1-a".
+		MachineType string `json:"machineType"`
+		Zone        string `json:"zone"`
+	}
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, err
+	}
+	zone := lastPathElement(instance.Zone)
+	var region string
+	if i := strings.LastIndexByte(zone, '-'); i > 0 {
+		region = zone[:i]
+	}
+	return cloudFields(lastPathElement(instance.MachineType), region, zone), nil
+}
+
+// readAzureMetadata reads the compute metadata of an Azure VM.
+func readAzureMetadata(ctx context.Context, client *http.Client, url string) (map[string]interface{}, error) {
+	data, err := getMetadata(ctx, client, http.MethodGet, url+"/metadata/instance/compute?api-version=2021-02-01", map[string]string{
+		"Metadata": "true",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var compute struct {
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+	}
+	if err := json.Unmarshal(data, &compute); err != nil {
+		return nil, err
+	}
+	return cloudFields(compute.VMSize, compute.Location, compute.Zone), nil
+}
+
+// cloudFields returns the cloud sub-fields with non-empty values.
+func cloudFields(instanceType, region, zone string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for field, value := range map[string]string{
+		FieldCloudInstanceType: instanceType,
+		FieldCloudRegion:       region,
+		FieldCloudZone:         zone,
+	} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+func lastPathElement(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
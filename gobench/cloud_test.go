@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_addCloudAWS(t *testing.T) {
+	const token = "secret-token"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Write([]byte(token))
+		case r.URL.Path == "/latest/dynamic/instance-identity/document":
+			if r.Header.Get("X-aws-ec2-metadata-token") != token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"instanceType":"c5.xlarge","region":"eu-west-1","availabilityZone":"eu-west-1b"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	providers := append([]cloudProvider(nil), cloudProviders...)
+	for i := range providers {
+		providers[i].url = srv.URL
+	}
+	doc := make(map[string]interface{})
+	addCloud(providers, doc)
+	assert.Equal(t, map[string]interface{}{
+		FieldCloudProvider:     "aws",
+		FieldCloudInstanceType: "c5.xlarge",
+		FieldCloudRegion:       "eu-west-1",
+		FieldCloudZone:         "eu-west-1b",
+	}, doc[FieldCloud])
+}
+
+func Test_addCloudGCP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/computeMetadata/v1/instance/" || r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"machineType":"projects/123/machineTypes/n2-standard-8","zone":"projects/123/zones/us-central1-a"}`))
+	}))
+	defer srv.Close()
+
+	providers := append([]cloudProvider(nil), cloudProviders...)
+	for i := range providers {
+		providers[i].url = srv.URL
+	}
+	doc := make(map[string]interface{})
+	addCloud(providers, doc)
+	assert.Equal(t, map[string]interface{}{
+		FieldCloudProvider:     "gcp",
+		FieldCloudInstanceType: "n2-standard-8",
+		FieldCloudRegion:       "us-central1",
+		FieldCloudZone:         "us-central1-a",
+	}, doc[FieldCloud])
+}
+
+func Test_addCloudUnreachable(t *testing.T) {
+	// A listener which never accepts connections, so that requests
+	// hang until they time out.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	providers := append([]cloudProvider(nil), cloudProviders...)
+	providers[0].url = hang.URL
+	providers[1].url = "http://" + ln.Addr().String()
+	providers[2].url = "http://127.0.0.1:1"
+	doc := make(map[string]interface{})
+	start := time.Now()
+	addCloud(providers, doc)
+	assert.Less(t, time.Since(start), cloudMetadataTimeout+time.Second)
+	assert.Empty(t, doc)
+}
+
+func Test_addHostCopiesNestedFields(t *testing.T) {
+	// Read the host details, so that they are not read over hostFields.
+	addHost(make(map[string]interface{}))
+	orig := hostFields
+	t.Cleanup(func() { hostFields = orig })
+	hostFields = map[string]interface{}{
+		FieldCloud: map[string]interface{}{FieldCloudProvider: "aws"},
+	}
+
+	doc1 := make(map[string]interface{})
+	addHost(doc1)
+	doc1[FieldCloud].(map[string]interface{})[FieldCloudProvider] = "changed"
+	doc2 := make(map[string]interface{})
+	addHost(doc2)
+	assert.Equal(t, map[string]interface{}{FieldCloudProvider: "aws"}, doc2[FieldCloud])
+}
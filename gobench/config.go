@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Config holds the configuration for indexing benchmarks into
+// Elasticsearch.
+type Config struct {
+	// URL is the Elasticsearch URL, e.g. http://localhost:9200.
+	URL string
+
+	// Index is the name of the index into which documents are
+	// indexed. It may contain date patterns; see ValidateIndexPattern.
+	Index string
+
+	// Username and Password are used for basic authentication.
+	// APIKey takes precedence over them, and BearerToken over all.
+	Username    string
+	Password    string
+	APIKey      string
+	BearerToken string
+
+	// CACert is the path of a PEM-encoded CA certificate used to verify
+	// the server certificate. Insecure disables verification. ClientCert
+	// and ClientKey are the paths of a PEM-encoded certificate and key
+	// for mutual TLS. These are used by NewHTTPClient.
+	CACert     string
+	Insecure   bool
+	ClientCert string
+	ClientKey  string
+
+	// Compress enables gzip compression of bulk request bodies.
+	Compress bool
+
+	// BulkMaxBytes is the approximate maximum size of each bulk request
+	// body, before compression. Zero means unlimited: documents are
+	// streamed in a single request as they are written, which cannot be
+	// retried.
+	BulkMaxBytes int
+
+	// Workers is the number of bulk requests sent concurrently. Values
+	// less than two mean requests are sent one at a time.
+	Workers int
+
+	// MaxRetries is the maximum number of times to retry requests which
+	// fail with a network error or a 429, 502, 503 or 504 status.
+	MaxRetries int
+
+	// Refresh is the value of the refresh parameter for bulk requests;
+	// one of RefreshValues. Empty and "false" are equivalent.
+	Refresh string
+
+	// Pipeline, if non-empty, is the ingest pipeline through which
+	// documents are indexed.
+	Pipeline string
+
+	// Dedup, if true, causes each document to be indexed with an ID
+	// derived from its commit and benchmark identity, so that indexing
+	// the same results again overwrites rather than duplicates them.
+	Dedup bool
+
+	// DedupSequence, if true along with Dedup, causes a sequence number
+	// to be appended to the ID of each document whose ID has already
+	// been used by the same Indexer or Output, e.g. for the results of
+	// "go test -count", so that they are all kept rather than overwriting
+	// each other. IDs remain stable across runs of the same results.
+	DedupSequence bool
+
+	// UseTemplate, if true, causes the mappings to be installed in a
+	// composable index template rather than on the index directly.
+	UseTemplate bool
+
+	// PerPackageIndex, if true, causes each document to be indexed into
+	// an index named after Index and the benchmark's package, e.g.
+	// gobench-github-com-foo-bar. It implies UseTemplate.
+	PerPackageIndex bool
+
+	// IndexFromTag, if non-empty, is the key of a tag whose value, when
+	// present on a document, is appended to Index to name the index into
+	// which it is indexed, e.g. gobench-search for the tag team=search.
+	// Documents without the tag are indexed into Index. It implies
+	// UseTemplate.
+	IndexFromTag string
+
+	// MappingOverrides, if non-nil, holds field properties which are
+	// deep-merged into the benchmark field mappings: objects present in
+	// both are merged recursively, and other values in MappingOverrides
+	// win on conflict. See ParseMappingOverrides.
+	MappingOverrides map[string]interface{}
+
+	// SortByTime, if true, causes the documents of files uploaded with
+	// Indexer.UploadFile to be read into memory and sorted by their
+	// execution time before they are sent.
+	SortByTime bool
+
+	// Alias, if non-empty, is a write alias into which documents are
+	// indexed in place of Index. If the alias does not exist, it is
+	// created pointing at a first index named after Index with the
+	// suffix "-000001", so that it can be rolled over.
+	Alias string
+
+	// AutoRollover, if true, causes Alias to be rolled over to a new
+	// index after documents are successfully indexed, if any of the
+	// conditions RolloverMaxAge, RolloverMaxDocs and RolloverMaxSize
+	// are met.
+	AutoRollover    bool
+	RolloverMaxAge  string
+	RolloverMaxDocs int
+	RolloverMaxSize string
+
+	// Shards and Replicas, if positive and non-nil respectively, are
+	// the numbers of primary shards and replicas of each shard set in
+	// the settings of the index or index template. Otherwise the
+	// cluster defaults apply.
+	Shards   int
+	Replicas *int
+
+	// WaitForActiveShards, if non-empty, is the number of shard copies
+	// which must be active before indexing proceeds: a positive integer,
+	// or "all". It is passed as wait_for_active_shards when creating the
+	// index or, when indices are created from an index template, with
+	// each bulk request, which creates them.
+	WaitForActiveShards string
+
+	// ILMPolicy, if non-empty, is the name of an ILM policy created
+	// with a hot-phase rollover at ILMMaxAge and/or ILMMaxSize, and
+	// attached to the index or index template.
+	ILMPolicy  string
+	ILMMaxAge  string
+	ILMMaxSize string
+
+	// Proxy, if non-empty, is the URL of the HTTP proxy through which
+	// requests are sent by clients returned by NewHTTPClient. Otherwise
+	// the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	Proxy string
+
+	// MaxIdleConnsPerHost and IdleConnTimeout configure the pool of
+	// idle connections kept by clients returned by NewHTTPClient. Zero
+	// values leave the defaults of http.DefaultTransport.
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit
+	// the phases of each request by clients returned by NewHTTPClient:
+	// connecting, the TLS handshake, and waiting for the response headers
+	// once the request is sent. Reading the response body is not limited,
+	// so that large responses are not interrupted; the overall duration
+	// of requests is limited by the deadline of their context. Zero
+	// values leave the defaults of http.DefaultTransport.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// Serverless, if true, indicates that URL is an Elasticsearch
+	// Serverless project, which is otherwise detected from the response
+	// of its root endpoint. The version is not requested, the latest
+	// version is assumed, and the shards, replicas and ILM policy, which
+	// Serverless rejects, are ignored.
+	Serverless bool
+
+	// Trace, if true, causes the DNS resolution, connection reuse, TLS
+	// handshake and time to first response byte of each request to be
+	// logged, whichever Client is used.
+	Trace bool
+
+	// Client is the HTTP client used for requests to Elasticsearch.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Logger receives log records, such as retried requests and, at
+	// debug level, Elasticsearch responses. If nil, slog.Default() is
+	// used.
+	Logger *slog.Logger
+}
+
+// logger returns cfg.Logger, or slog.Default() if it is nil.
+func (cfg Config) logger() *slog.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return slog.Default()
+}
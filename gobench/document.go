@@ -0,0 +1,394 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// Benchmark holds the result of a benchmark.
+type Benchmark struct {
+	parse.Benchmark
+
+	// Extra holds any metrics reported with testing.B.ReportMetric,
+	// keyed by unit with "/" replaced by "_".
+	Extra map[string]float64
+
+	// NsPerOpStats, if non-nil, holds statistics for ns/op across
+	// repeated runs of the benchmark.
+	NsPerOpStats *Stats
+
+	// Raw, if non-empty, is the line of benchmark output from which the
+	// result was parsed.
+	Raw string
+}
+
+// Stats holds summary statistics for a metric across repeated runs of a
+// benchmark, e.g. with "go test -count=10".
+type Stats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+}
+
+// Document is an Elasticsearch document describing a benchmark result.
+type Document map[string]interface{}
+
+// DocumentOptions controls the enrichment of documents returned by its
+// NewDocument method. The zero value enables all enrichment.
+type DocumentOptions struct {
+	// NoHost disables the addition of details of the host.
+	NoHost bool
+
+	// NoVCS disables the addition of details of the version control
+	// revision, which requires running git or hg for each document.
+	// This is useful when indexing archived results with no working
+	// tree.
+	NoVCS bool
+
+	// RepoDir, if non-empty, is a directory in the working tree from
+	// which the version control revision is read, instead of the
+	// directory of each package as found by go/build. This is needed
+	// when packages cannot be found, e.g. when running prebuilt test
+	// binaries outside GOPATH and the module cache.
+	RepoDir string
+
+	// ZeroFillMemory, if true, causes documents for benchmarks without
+	// memory statistics, i.e. run without -benchmem, to have zero
+	// FieldAllocedBytesPerOp and FieldAllocsPerOp rather than omitting
+	// them, so that these fields are always present.
+	ZeroFillMemory bool
+
+	// StoreRaw, if true, causes the line of benchmark output from which
+	// each result was parsed, if known, to be recorded as FieldRaw.
+	StoreRaw bool
+
+	// Fields, if non-nil, are added to each document, e.g. details of
+	// the run which are the same for all benchmarks.
+	Fields Document
+}
+
+// NewDocument returns a Document for the benchmark result b, from the
+// package pkg, run on goos/goarch with the given cpu at timestamp. The
+// document is enriched with details of the host, the version control
+// revision of pkg, and the CI build. Tags are added under the "tags"
+// field, so that they cannot replace any of these fields.
+func NewDocument(
+	b Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) Document {
+	return DocumentOptions{}.NewDocument(b, pkg, goos, goarch, cpu, tags, timestamp)
+}
+
+// NewDocument is like the NewDocument function, but enriches the document
+// according to opts.
+func (opts DocumentOptions) NewDocument(
+	b Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) Document {
+	fullName, gomaxprocs := SplitGOMAXPROCS(b.Name)
+	name, params, segments := splitSubBenchmarks(fullName)
+	doc := Document{
+		FieldExecutedAt: timestamp,
+		FieldName:       name,
+		FieldFullName:   fullName,
+		FieldIterations: b.N,
+		FieldPkg:        pkg,
+		FieldGoVersion:  runtime.Version(),
+		FieldGOOS:       goos,
+		FieldGOARCH:     goarch,
+	}
+	if cpu != "" {
+		doc[FieldCPU] = cpu
+	}
+	if gomaxprocs > 0 {
+		doc[FieldGOMAXPROCS] = gomaxprocs
+	}
+	if len(params) > 0 {
+		doc[FieldParams] = params
+	}
+	if len(segments) > 0 {
+		doc[FieldSegments] = segments
+	}
+	if b.Measured&parse.NsPerOp != 0 {
+		doc[FieldNSPerOp] = b.NsPerOp
+		// The wall time spent in the benchmark function, excluding
+		// setup and the runs used to determine N.
+		if b.N > 0 {
+			doc[FieldTotalNS] = float64(b.N) * b.NsPerOp
+		}
+	}
+	if b.NsPerOpStats != nil {
+		doc[FieldNSPerOpStats] = b.NsPerOpStats
+	}
+	if b.Measured&parse.MBPerS != 0 {
+		doc[FieldMBPerS] = b.MBPerS
+	}
+	if b.Measured&parse.AllocedBytesPerOp != 0 || opts.ZeroFillMemory {
+		doc[FieldAllocedBytesPerOp] = b.AllocedBytesPerOp
+	}
+	if b.Measured&parse.AllocsPerOp != 0 || opts.ZeroFillMemory {
+		doc[FieldAllocsPerOp] = b.AllocsPerOp
+	}
+	if len(b.Extra) > 0 {
+		doc[FieldExtraMetrics] = b.Extra
+	}
+	if opts.StoreRaw && b.Raw != "" {
+		doc[FieldRaw] = b.Raw
+	}
+
+	if !opts.NoHost {
+		addHost(doc)
+	}
+	if !opts.NoVCS {
+		if opts.RepoDir != "" {
+			addVCSDir(opts.RepoDir, doc)
+		} else {
+			addVCS(pkg, doc)
+		}
+	}
+	addCI(doc)
+	for field, value := range opts.Fields {
+		doc[field] = value
+	}
+	if len(tags) > 0 {
+		doc[FieldTags] = tags
+	}
+	return doc
+}
+
+// EncodeBulkAction encodes doc as an Elasticsearch bulk index action,
+// followed by the document itself. Date patterns in cfg.Index are expanded
+// using the document's execution time, or cfg.Alias is used in place of
+// cfg.Index if it is set. If cfg.PerPackageIndex is set, the document's
+// package is appended; if cfg.IndexFromTag is set and the document has
+// that tag, its value is appended. A nil esVersion is treated
+// as the latest version of Elasticsearch.
+func EncodeBulkAction(encoder *json.Encoder, doc Document, cfg Config, esVersion *semver.Version) error {
+	return encodeBulkAction(encoder, doc, cfg, esVersion, nil)
+}
+
+// encodeBulkAction implements EncodeBulkAction, disambiguating document
+// IDs with ids if it is non-nil.
+func encodeBulkAction(encoder *json.Encoder, doc Document, cfg Config, esVersion *semver.Version, ids idSequence) error {
+	timestamp, _ := doc[FieldExecutedAt].(time.Time)
+
+	type Index struct {
+		Index string `json:"_index"`
+		Type  string `json:"_type,omitempty"`
+		ID    string `json:"_id,omitempty"`
+	}
+	index := expandIndexName(cfg.Index, timestamp)
+	if cfg.Alias != "" {
+		index = cfg.Alias
+	}
+	if cfg.PerPackageIndex {
+		pkg, _ := doc[FieldPkg].(string)
+		index = packageIndexName(index, pkg)
+	}
+	if cfg.IndexFromTag != "" {
+		tags, _ := doc[FieldTags].(map[string]string)
+		if value, ok := tags[cfg.IndexFromTag]; ok {
+			index = tagIndexName(index, value)
+		}
+	}
+	indexAction := struct {
+		Index Index `json:"index"`
+	}{Index: Index{Index: index}}
+	if esTypeNames(esVersion).actions {
+		indexAction.Index.Type = "_doc"
+	}
+	if cfg.Dedup {
+		indexAction.Index.ID = ids.next(documentID(doc))
+	}
+
+	if err := encoder.Encode(indexAction); err != nil {
+		return err
+	}
+	return encoder.Encode(doc)
+}
+
+// documentID returns a stable ID for doc, derived from the commit and the
+// fields identifying the benchmark, or "" if doc has no commit. Without a
+// commit, results from different runs cannot be told apart.
+func documentID(doc Document) string {
+	var commit interface{}
+	for _, field := range []string{FieldGit, FieldHg} {
+		if vcs, ok := doc[field].(map[string]interface{}); ok {
+			commit = vcs[FieldGitCommit]
+			break
+		}
+	}
+	if commit == nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, value := range []interface{}{
+		commit,
+		doc[FieldPkg],
+		doc[FieldFullName],
+		doc[FieldGOOS],
+		doc[FieldGOARCH],
+		doc[FieldGOMAXPROCS],
+	} {
+		fmt.Fprintf(h, "%v\x00", value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idSequence counts the uses of each document ID within a run, so that
+// documents which would otherwise share an ID, such as the results of
+// "go test -count", are all kept when Config.DedupSequence is set.
+type idSequence map[string]int
+
+// newIDSequence returns an idSequence for a run with cfg, or nil if
+// cfg.DedupSequence is not set.
+func newIDSequence(cfg Config) idSequence {
+	if !cfg.Dedup || !cfg.DedupSequence {
+		return nil
+	}
+	return make(idSequence)
+}
+
+// next returns id, followed by a hyphen and its sequence number if it has
+// already been used in the run. The first use keeps id unchanged, and so
+// indexing the same results again in another run produces the same IDs.
+// If s is nil or id is empty, id is returned unchanged.
+func (s idSequence) next(id string) string {
+	if s == nil || id == "" {
+		return id
+	}
+	s[id]++
+	if n := s[id]; n > 1 {
+		return fmt.Sprintf("%s-%d", id, n)
+	}
+	return id
+}
+
+// splitSubBenchmarks splits a benchmark name, such as
+// "BenchmarkCache/size=1024/readers=4", into the name of the top-level
+// benchmark and the "/"-separated names of its sub-benchmarks. Names of
+// the form key=value are returned in params, and any others in segments.
+func splitSubBenchmarks(fullName string) (name string, params map[string]string, segments []string) {
+	parts := strings.Split(fullName, "/")
+	for _, part := range parts[1:] {
+		if i := strings.IndexRune(part, '='); i > 0 {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[part[:i]] = part[i+1:]
+		} else {
+			segments = append(segments, part)
+		}
+	}
+	return parts[0], params, segments
+}
+
+// SplitGOMAXPROCS splits the "-N" suffix which the testing package adds to
+// benchmark names when GOMAXPROCS is greater than one, returning the name
+// without the suffix and the value of N. If the name has no such suffix,
+// it is returned unchanged along with zero.
+func SplitGOMAXPROCS(name string) (string, int) {
+	i := strings.LastIndexByte(name, '-')
+	if i == -1 {
+		return name, 0
+	}
+	n, err := strconv.Atoi(name[i+1:])
+	if err != nil || n <= 0 {
+		return name, 0
+	}
+	return name[:i], n
+}
+
+// ParseExtraMetrics returns the metrics reported with
+// testing.B.ReportMetric in a benchmark result line, keyed by unit with "/"
+// replaced by "_", or nil if there are none.
+//
+// The native metrics, which are parsed into parse.Benchmark, are
+// identified by their units, ns/op, MB/s, B/op and allocs/op, wherever
+// they appear in the line. Metrics with any other unit are returned,
+// whatever their position, e.g. "tokens/op".
+//
+// Values may be decimal, e.g. "1234.5", in scientific notation, e.g.
+// "1.2e+06", or grouped with commas as thousands separators, e.g.
+// "1,234.5". Metrics with other values are skipped.
+func ParseExtraMetrics(line string) map[string]float64 {
+	entries := strings.Split(line, "\t")
+	// If the result has less than 3 columns, it doesn't contain
+	// extra metrics to be reported.
+	if len(entries) < 3 {
+		return nil
+	}
+
+	// Ignore the first two entries since they're fixed to be the
+	// benchmark name and iterations.
+	result := make(map[string]float64)
+	for _, entry := range entries[2:] {
+		parts := strings.Split(strings.TrimSpace(entry), " ")
+		if len(parts) < 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[1])
+		if isNativeUnit(key) {
+			continue
+		}
+		value, err := parseMetricValue(strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+		escapedKey := strings.ReplaceAll(key, "/", "_")
+		result[escapedKey] = value
+	}
+	if len(result) > 0 {
+		return result
+	}
+	return nil
+}
+
+// isNativeUnit reports whether unit is that of a metric parsed into
+// parse.Benchmark by the testing package's own columns.
+func isNativeUnit(unit string) bool {
+	switch unit {
+	case "ns/op", "MB/s", "B/op", "allocs/op":
+		return true
+	}
+	return false
+}
+
+// parseMetricValue parses a metric value, ignoring commas used as
+// thousands separators.
+func parseMetricValue(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+}
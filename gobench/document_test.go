@@ -0,0 +1,519 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_parseExtraMetrics(t *testing.T) {
+	f, err := os.Open("../testdata/benchmark-result.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type args struct {
+		line string
+	}
+	expected := []map[string]float64{
+		{
+			"error_responses_sec": 0,
+			"errors_sec":          320.7,
+			"events_sec":          15988,
+			"metrics_sec":         735.5,
+			"spans_sec":           10546,
+			"txs_sec":             4386},
+		{
+			"error_responses_sec": 0,
+			"errors_sec":          293.8,
+			"events_sec":          12066,
+			"metrics_sec":         716.6,
+			"spans_sec":           6361,
+			"txs_sec":             4695},
+		{
+			"error_responses_sec": 0,
+			"errors_sec":          132.6,
+			"events_sec":          12928,
+			"metrics_sec":         3899,
+			"spans_sec":           7512,
+			"txs_sec":             1385},
+		{
+			"error_responses_sec": 0,
+			"errors_sec":          503.9,
+			"events_sec":          14116,
+			"metrics_sec":         1037,
+			"spans_sec":           8303,
+			"txs_sec":             4272},
+		nil, // Second to last entry is ignored.
+		nil, // Last entry is ignored.
+	}
+	scanner := bufio.NewScanner(f)
+	for i := 0; scanner.Scan(); i++ {
+		result := ParseExtraMetrics(scanner.Text())
+		if len(expected) <= i {
+			t.Errorf("expected entry not found for index %d", i)
+			return
+		}
+		assert.Equal(t, expected[i], result)
+	}
+}
+
+func Test_parseExtraMetricsValues(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected map[string]float64
+	}{
+		{"1.2e+06", map[string]float64{"ops_sec": 1.2e6}},
+		{"1,234.5", map[string]float64{"ops_sec": 1234.5}},
+		{"1,234,567", map[string]float64{"ops_sec": 1234567}},
+		{"0", map[string]float64{"ops_sec": 0}},
+		{"NaN-ish", nil},
+	} {
+		line := "BenchmarkFoo-8\t100\t12.5 ns/op\t" + tc.value + " ops/sec"
+		assert.Equal(t, tc.expected, ParseExtraMetrics(line), tc.value)
+	}
+}
+
+func Test_parseExtraMetricsNativeColumns(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected map[string]float64
+	}{{
+		line:     "BenchmarkFoo-8\t100\t12.5 ns/op\t42 tokens/op\t1024 B/op\t3 allocs/op",
+		expected: map[string]float64{"tokens_op": 42},
+	}, {
+		line:     "BenchmarkFoo-8\t100\t12.5 ns/op\t80.00 MB/s\t42 tokens/op\t7 ns/token",
+		expected: map[string]float64{"tokens_op": 42, "ns_token": 7},
+	}, {
+		line:     "BenchmarkFoo-8\t100\t12.5 ns/op\t80.00 MB/s\t1024 B/op\t3 allocs/op",
+		expected: nil,
+	}, {
+		line:     "BenchmarkFoo-8\t100\t12.5 ns/op\t1024 B/op\t42 tokens/op",
+		expected: map[string]float64{"tokens_op": 42},
+	}} {
+		assert.Equal(t, tc.expected, ParseExtraMetrics(tc.line), tc.line)
+	}
+}
+
+func Test_parseExtraMetricsReorderedColumns(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected map[string]float64
+	}{{
+		line:     "BenchmarkFoo-8\t100\t42 tokens/op\t12.5 ns/op",
+		expected: map[string]float64{"tokens_op": 42},
+	}, {
+		line:     "BenchmarkFoo-8\t100\t3 allocs/op\t42 tokens/op\t1024 B/op\t12.5 ns/op",
+		expected: map[string]float64{"tokens_op": 42},
+	}, {
+		line:     "BenchmarkFoo-8\t100\t7 ns/token\t80.00 MB/s\t12.5 ns/op\t42 tokens/op",
+		expected: map[string]float64{"tokens_op": 42, "ns_token": 7},
+	}, {
+		line:     "BenchmarkFoo-8\t100\t1024 B/op\t80.00 MB/s\t3 allocs/op\t12.5 ns/op",
+		expected: nil,
+	}} {
+		assert.Equal(t, tc.expected, ParseExtraMetrics(tc.line), tc.line)
+	}
+}
+
+func Test_EncodeBulkAction(t *testing.T) {
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	encode := func(esVersion *semver.Version) map[string]interface{} {
+		var buf bytes.Buffer
+		require.NoError(t, EncodeBulkAction(
+			json.NewEncoder(&buf),
+			NewDocument(b, "", "linux", "amd64", "", nil, time.Now()),
+			Config{Index: "gobench"}, esVersion,
+		))
+		var action map[string]interface{}
+		require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+		return action["index"].(map[string]interface{})
+	}
+	assert.Equal(t, map[string]interface{}{"_index": "gobench", "_type": "_doc"}, encode(&semver.Version{Major: 7, Minor: 11, Patch: 1}))
+	assert.Equal(t, map[string]interface{}{"_index": "gobench"}, encode(&semver.Version{Major: 8}))
+	assert.Equal(t, map[string]interface{}{"_index": "gobench"}, encode(nil))
+}
+
+func Test_EncodeBulkActionLines(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, name := range []string{"BenchmarkFoo-8", "BenchmarkBar-8"} {
+		b := Benchmark{Benchmark: parse.Benchmark{Name: name, N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+		doc := NewDocument(b, "", "linux", "amd64", "", nil, time.Now())
+		require.NoError(t, EncodeBulkAction(encoder, doc, Config{Index: "gobench"}, nil))
+	}
+
+	// The bulk API requires exactly one action line followed by one
+	// document line per document, each terminated by a newline.
+	body := buf.String()
+	require.True(t, strings.HasSuffix(body, "\n"))
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	require.Len(t, lines, 4)
+	for i, line := range lines {
+		var value map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &value), "line %d: %s", i+1, line)
+		if i%2 == 0 {
+			assert.Equal(t, map[string]interface{}{"index": map[string]interface{}{"_index": "gobench"}}, value)
+		} else {
+			assert.Contains(t, value, FieldName)
+		}
+	}
+}
+
+func Test_EncodeBulkActionIndexPattern(t *testing.T) {
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	timestamp := time.Date(2024, time.January, 15, 23, 30, 0, 0, time.UTC)
+	for index, expected := range map[string]string{
+		"gobench":                    "gobench",
+		"gobench-{2006.01.02}":       "gobench-2024.01.15",
+		"gobench-{2006}-{01}":        "gobench-2024-01",
+		"gobench-{Jan-2006}-monthly": "gobench-jan-2024-monthly",
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, EncodeBulkAction(
+			json.NewEncoder(&buf),
+			NewDocument(b, "", "linux", "amd64", "", nil, timestamp),
+			Config{Index: index}, nil,
+		))
+		var action map[string]map[string]interface{}
+		require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+		assert.Equal(t, expected, action["index"]["_index"], index)
+	}
+}
+
+func Test_EncodeBulkActionPerPackageIndex(t *testing.T) {
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	timestamp := time.Date(2024, time.January, 15, 23, 30, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	require.NoError(t, EncodeBulkAction(
+		json.NewEncoder(&buf),
+		NewDocument(b, "github.com/foo/bar", "linux", "amd64", "", nil, timestamp),
+		Config{Index: "gobench-{2006.01}", PerPackageIndex: true}, nil,
+	))
+	var action map[string]map[string]interface{}
+	require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+	assert.Equal(t, "gobench-2024.01-github-com-foo-bar", action["index"]["_index"])
+}
+
+func Test_EncodeBulkActionIndexFromTag(t *testing.T) {
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	timestamp := time.Date(2024, time.January, 15, 23, 30, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		cfg      Config
+		tags     map[string]string
+		expected string
+	}{
+		"tag": {
+			cfg:      Config{Index: "gobench", IndexFromTag: "team"},
+			tags:     map[string]string{"team": "search", "runner": "c5"},
+			expected: "gobench-search",
+		},
+		"sanitized": {
+			cfg:      Config{Index: "gobench", IndexFromTag: "team"},
+			tags:     map[string]string{"team": "Search/Relevance"},
+			expected: "gobench-search-relevance",
+		},
+		"missing tag": {
+			cfg:      Config{Index: "gobench", IndexFromTag: "team"},
+			tags:     map[string]string{"runner": "c5"},
+			expected: "gobench",
+		},
+		"no tags": {
+			cfg:      Config{Index: "gobench", IndexFromTag: "team"},
+			expected: "gobench",
+		},
+		"empty value": {
+			cfg:      Config{Index: "gobench", IndexFromTag: "team"},
+			tags:     map[string]string{"team": "*"},
+			expected: "gobench",
+		},
+		"date pattern and package": {
+			cfg:      Config{Index: "gobench-{2006.01}", IndexFromTag: "team", PerPackageIndex: true},
+			tags:     map[string]string{"team": "search"},
+			expected: "gobench-2024.01-github-com-foo-bar-search",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeBulkAction(
+				json.NewEncoder(&buf),
+				NewDocument(b, "github.com/foo/bar", "linux", "amd64", "", tc.tags, timestamp),
+				tc.cfg, nil,
+			))
+			var action map[string]map[string]interface{}
+			require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+			assert.Equal(t, tc.expected, action["index"]["_index"])
+		})
+	}
+}
+
+func Test_documentID(t *testing.T) {
+	newDoc := func(commit, name string, gomaxprocs int) Document {
+		return Document{
+			FieldGit:        map[string]interface{}{FieldGitCommit: commit},
+			FieldPkg:        "example.com/foo",
+			FieldFullName:   name,
+			FieldGOOS:       "linux",
+			FieldGOARCH:     "amd64",
+			FieldGOMAXPROCS: gomaxprocs,
+			FieldNSPerOp:    12.5,
+		}
+	}
+	id := documentID(newDoc("abc123", "BenchmarkFoo", 8))
+	assert.Len(t, id, 64)
+
+	same := newDoc("abc123", "BenchmarkFoo", 8)
+	same[FieldNSPerOp] = 25.0
+	same[FieldExecutedAt] = time.Now()
+	assert.Equal(t, id, documentID(same))
+
+	assert.NotEqual(t, id, documentID(newDoc("def456", "BenchmarkFoo", 8)))
+	assert.NotEqual(t, id, documentID(newDoc("abc123", "BenchmarkBar", 8)))
+	assert.NotEqual(t, id, documentID(newDoc("abc123", "BenchmarkFoo/size=1", 8)))
+	assert.NotEqual(t, id, documentID(newDoc("abc123", "BenchmarkFoo", 4)))
+
+	noCommit := newDoc("", "BenchmarkFoo", 8)
+	delete(noCommit, FieldGit)
+	assert.Equal(t, "", documentID(noCommit))
+}
+
+func Test_EncodeBulkActionDedup(t *testing.T) {
+	stubCommands(t, map[string]string{
+		"git log": "0123456789abcdef\x001700000000\x00Subject\x00a\x00a@example.com\x001700000000\n",
+	})
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	encode := func(dedup bool) map[string]interface{} {
+		var buf bytes.Buffer
+		require.NoError(t, EncodeBulkAction(
+			json.NewEncoder(&buf),
+			NewDocument(b, "github.com/elastic/gobench", "linux", "amd64", "", nil, time.Now()),
+			Config{Index: "gobench", Dedup: dedup}, nil,
+		))
+		var action map[string]map[string]interface{}
+		require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+		return action["index"]
+	}
+	assert.NotContains(t, encode(false), "_id")
+	first, second := encode(true), encode(true)
+	assert.Len(t, first["_id"], 64)
+	assert.Equal(t, first["_id"], second["_id"])
+}
+
+func Test_NewBulkOutputDedupSequence(t *testing.T) {
+	doc := Document{
+		FieldGit:      map[string]interface{}{FieldGitCommit: "0123456789abcdef"},
+		FieldPkg:      "example.com/foo",
+		FieldFullName: "BenchmarkFoo",
+	}
+	encodeIDs := func(cfg Config) []interface{} {
+		var buf bytes.Buffer
+		out := NewBulkOutput(&buf, cfg, nil)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, out.Write(doc))
+		}
+		var ids []interface{}
+		decoder := json.NewDecoder(&buf)
+		for decoder.More() {
+			var action map[string]map[string]interface{}
+			var doc Document
+			require.NoError(t, decoder.Decode(&action))
+			require.NoError(t, decoder.Decode(&doc))
+			ids = append(ids, action["index"]["_id"])
+		}
+		return ids
+	}
+
+	id := documentID(doc)
+	cfg := Config{Index: "gobench", Dedup: true}
+	assert.Equal(t, []interface{}{id, id, id}, encodeIDs(cfg))
+
+	cfg.DedupSequence = true
+	ids := encodeIDs(cfg)
+	assert.Equal(t, []interface{}{id, id + "-2", id + "-3"}, ids)
+	// The same results produce the same IDs in another run.
+	assert.Equal(t, ids, encodeIDs(cfg))
+}
+
+func Test_idSequence(t *testing.T) {
+	var none idSequence
+	assert.Equal(t, "a", none.next("a"))
+	assert.Equal(t, "a", none.next("a"))
+
+	s := make(idSequence)
+	assert.Equal(t, "a", s.next("a"))
+	assert.Equal(t, "b", s.next("b"))
+	assert.Equal(t, "a-2", s.next("a"))
+	assert.Equal(t, "", s.next(""))
+	assert.Equal(t, "", s.next(""))
+}
+
+func Test_DocumentOptions(t *testing.T) {
+	stubCommands(t, map[string]string{
+		"git log": "0123456789abcdef\x001700000000\x00Subject\x00a\x00a@example.com\x001700000000\n",
+	})
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	newDocument := func(opts DocumentOptions) Document {
+		return opts.NewDocument(b, "github.com/elastic/gobench", "linux", "amd64", "", nil, time.Now())
+	}
+
+	doc := newDocument(DocumentOptions{})
+	assert.Contains(t, doc, FieldGit)
+	assert.Contains(t, doc, FieldNumCPU)
+
+	doc = newDocument(DocumentOptions{NoVCS: true})
+	assert.NotContains(t, doc, FieldGit)
+	assert.Contains(t, doc, FieldNumCPU)
+
+	doc = newDocument(DocumentOptions{NoHost: true})
+	assert.Contains(t, doc, FieldGit)
+	assert.NotContains(t, doc, FieldNumCPU)
+	assert.NotContains(t, doc, FieldHostname)
+
+	doc = newDocument(DocumentOptions{Fields: Document{"run": "1"}})
+	assert.Equal(t, "1", doc["run"])
+}
+
+func Test_NewDocumentTotalNS(t *testing.T) {
+	opts := DocumentOptions{NoHost: true, NoVCS: true}
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 2000, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	doc := opts.NewDocument(b, "", "linux", "amd64", "", nil, time.Now())
+	assert.Equal(t, 25000.0, doc[FieldTotalNS])
+
+	// Without ns/op, e.g. for a benchmark reporting only custom metrics,
+	// the total cannot be computed.
+	b = Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 2000}, Extra: map[string]float64{"events_sec": 10}}
+	doc = opts.NewDocument(b, "", "linux", "amd64", "", nil, time.Now())
+	assert.NotContains(t, doc, FieldTotalNS)
+}
+
+func Test_DocumentOptionsZeroFillMemory(t *testing.T) {
+	withoutMem := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	withMem := Benchmark{Benchmark: parse.Benchmark{
+		Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, AllocedBytesPerOp: 64, AllocsPerOp: 2,
+		Measured: parse.NsPerOp | parse.AllocedBytesPerOp | parse.AllocsPerOp,
+	}}
+	for _, zeroFill := range []bool{false, true} {
+		opts := DocumentOptions{NoHost: true, NoVCS: true, ZeroFillMemory: zeroFill}
+
+		doc := opts.NewDocument(withoutMem, "", "linux", "amd64", "", nil, time.Now())
+		if zeroFill {
+			assert.Equal(t, uint64(0), doc[FieldAllocedBytesPerOp])
+			assert.Equal(t, uint64(0), doc[FieldAllocsPerOp])
+		} else {
+			assert.NotContains(t, doc, FieldAllocedBytesPerOp)
+			assert.NotContains(t, doc, FieldAllocsPerOp)
+		}
+
+		doc = opts.NewDocument(withMem, "", "linux", "amd64", "", nil, time.Now())
+		assert.Equal(t, uint64(64), doc[FieldAllocedBytesPerOp], zeroFill)
+		assert.Equal(t, uint64(2), doc[FieldAllocsPerOp], zeroFill)
+	}
+}
+
+func Test_DocumentOptionsRepoDir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_AUTHOR_DATE", "1700000000 +0000")
+	t.Setenv("GIT_COMMITTER_DATE", "1700000000 +0000")
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=Gopher", "-c", "user.email=gopher@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, "%s", output)
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.go"), []byte("package foo\n"), 0644))
+	git("add", "foo.go")
+	git("commit", "-q", "-m", "Add foo")
+	commit := git("rev-parse", "HEAD")
+
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	opts := DocumentOptions{RepoDir: dir}
+	// The package cannot be found by go/build, as with a prebuilt test binary.
+	doc := opts.NewDocument(b, "example.com/prebuilt/foo", "linux", "amd64", "", nil, time.Now())
+	require.Contains(t, doc, FieldGit)
+	gitFields := doc[FieldGit].(map[string]interface{})
+	assert.Equal(t, commit, gitFields[FieldGitCommit])
+	assert.Equal(t, "Add foo", gitFields[FieldGitSubject])
+	assert.Equal(t, "main", gitFields[FieldGitBranch])
+	assert.Equal(t, false, gitFields[FieldGitDirty])
+	assert.Equal(t, map[string]interface{}{
+		FieldGitAuthorName:  "Gopher",
+		FieldGitAuthorEmail: "gopher@example.com",
+		FieldGitAuthorDate:  time.Unix(1700000000, 0).UTC(),
+	}, gitFields[FieldGitAuthor])
+
+	doc = DocumentOptions{}.NewDocument(b, "example.com/prebuilt/foo", "linux", "amd64", "", nil, time.Now())
+	assert.NotContains(t, doc, FieldGit)
+}
+
+func Test_splitGOMAXPROCS(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		expected   string
+		gomaxprocs int
+	}{
+		{"BenchmarkFoo-16", "BenchmarkFoo", 16},
+		{"BenchmarkFoo", "BenchmarkFoo", 0},
+		{"Benchmark-Weird-Name", "Benchmark-Weird-Name", 0},
+		{"BenchmarkFoo/size=1024-8", "BenchmarkFoo/size=1024", 8},
+		{"BenchmarkFoo-", "BenchmarkFoo-", 0},
+	} {
+		name, gomaxprocs := SplitGOMAXPROCS(tc.name)
+		assert.Equal(t, tc.expected, name, tc.name)
+		assert.Equal(t, tc.gomaxprocs, gomaxprocs, tc.name)
+	}
+}
+
+func Test_splitSubBenchmarks(t *testing.T) {
+	for _, tc := range []struct {
+		fullName string
+		name     string
+		params   map[string]string
+		segments []string
+	}{
+		{fullName: "BenchmarkX", name: "BenchmarkX"},
+		{fullName: "BenchmarkX/a=1/b=2", name: "BenchmarkX", params: map[string]string{"a": "1", "b": "2"}},
+		{fullName: "BenchmarkX/small/parallel", name: "BenchmarkX", segments: []string{"small", "parallel"}},
+		{fullName: "BenchmarkX/json/size=1024", name: "BenchmarkX", params: map[string]string{"size": "1024"}, segments: []string{"json"}},
+		{fullName: "BenchmarkX/=1", name: "BenchmarkX", segments: []string{"=1"}},
+	} {
+		name, params, segments := splitSubBenchmarks(tc.fullName)
+		assert.Equal(t, tc.name, name, tc.fullName)
+		assert.Equal(t, tc.params, params, tc.fullName)
+		assert.Equal(t, tc.segments, segments, tc.fullName)
+	}
+}
@@ -0,0 +1,214 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+type esError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (e *esError) Error() string {
+	return e.Reason
+}
+
+const (
+	exceptionResourceAlreadyExists = "resource_already_exists_exception"
+)
+
+// esStatusError is returned by getEsInfo when Elasticsearch responds
+// with an unexpected status code.
+type esStatusError struct {
+	statusCode int
+}
+
+func (e *esStatusError) Error() string {
+	return fmt.Sprintf("received unexpected %d status code", e.statusCode)
+}
+
+// serverlessBuildFlavor is the build flavor reported in the root endpoint
+// response of Elasticsearch Serverless.
+const serverlessBuildFlavor = "serverless"
+
+// esInfo holds the details of the Elasticsearch cluster returned by
+// getEsInfo.
+type esInfo struct {
+	version    *semver.Version
+	serverless bool
+}
+
+// getEsInfo requests the root endpoint of Elasticsearch, returning its
+// version and whether it is Elasticsearch Serverless.
+func getEsInfo(ctx context.Context, cfg Config) (esInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return esInfo{}, err
+	}
+	resp, err := cfg.do(req)
+	if err != nil {
+		return esInfo{}, err
+	}
+	var root struct {
+		Version struct {
+			Number      string
+			BuildFlavor string `json:"build_flavor"`
+		} `json:"version"`
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return esInfo{}, &esStatusError{statusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return esInfo{}, err
+	}
+	info := esInfo{serverless: root.Version.BuildFlavor == serverlessBuildFlavor}
+	info.version, err = semver.New(root.Version.Number)
+	return info, err
+}
+
+// getEsVersion returns the version of Elasticsearch reported by getEsInfo.
+func getEsVersion(ctx context.Context, cfg Config) (*semver.Version, error) {
+	info, err := getEsInfo(ctx, cfg)
+	return info.version, err
+}
+
+// bulkItemsError is returned by handleResponse for a bulk request in which
+// one or more items failed.
+type bulkItemsError struct {
+	failed []bulkItemFailure
+	total  int
+
+	// names, if set, holds the names of the benchmarks in the request,
+	// in order, for identifying failed items.
+	names []string
+
+	// offset is added to each item's position when reporting it, so
+	// that positions are relative to all documents sent.
+	offset int
+}
+
+// bulkItemFailure describes a failed item in a bulk response.
+type bulkItemFailure struct {
+	// position is the 0-based position of the item in the request.
+	position int
+	status   int
+	errType  string
+	reason   string
+}
+
+func (e *bulkItemsError) Error() string {
+	msgs := make([]string, len(e.failed))
+	for i, item := range e.failed {
+		msg := fmt.Sprintf("item %d", e.offset+item.position+1)
+		if item.position < len(e.names) && e.names[item.position] != "" {
+			msg += fmt.Sprintf(" (%s)", e.names[item.position])
+		}
+		msgs[i] = fmt.Sprintf("%s: status %d: %s: %s", msg, item.status, item.errType, item.reason)
+	}
+	return fmt.Sprintf(
+		"%d of %d bulk items failed: %s",
+		len(e.failed), e.total, strings.Join(msgs, "; "),
+	)
+}
+
+// bulkItemFailures returns the failed items in a bulk response; those with
+// a status of 400 or greater, or an error.
+func bulkItemFailures(items []interface{}) []bulkItemFailure {
+	var failed []bulkItemFailure
+	for i, item := range items {
+		item, _ := item.(map[string]interface{})
+		for _, result := range item {
+			result, _ := result.(map[string]interface{})
+			status, _ := result["status"].(float64)
+			errorObj, hasError := result["error"].(map[string]interface{})
+			if status < 400 && !hasError {
+				continue
+			}
+			failure := bulkItemFailure{position: i, status: int(status)}
+			failure.errType, _ = errorObj["type"].(string)
+			failure.reason, _ = errorObj["reason"].(string)
+			failed = append(failed, failure)
+		}
+	}
+	return failed
+}
+
+// handleResponse reads and closes the response body, returning an error
+// if the request failed or, for bulk requests, if any item failed.
+// Successful responses are logged to logger at debug level.
+func handleResponse(resp *http.Response, logger *slog.Logger) error {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(body, &result); err != nil {
+		return errors.Wrapf(err, "error decoding %s response", resp.Status)
+	}
+	if resp.StatusCode == http.StatusOK {
+		logger.Debug("Elasticsearch response",
+			"method", resp.Request.Method,
+			"url", resp.Request.URL.Redacted(),
+			"status", resp.StatusCode,
+			"body", json.RawMessage(body),
+		)
+		if bulkErrors, _ := result["errors"].(bool); bulkErrors {
+			items, _ := result["items"].([]interface{})
+			return &bulkItemsError{failed: bulkItemFailures(items), total: len(items)}
+		}
+		return nil
+	}
+	errorObj, ok := result["error"].(map[string]interface{})
+	if !ok {
+		return errors.Errorf("%s", resp.Status)
+	}
+	errType, _ := errorObj["type"].(string)
+	errReason, _ := errorObj["reason"].(string)
+	return &esError{
+		Type:   errType,
+		Reason: errReason,
+	}
+}
+
+// setAuth sets the Authorization header on req according to cfg.
+// Only one authentication method is used: a bearer token takes
+// precedence over an API key, which takes precedence over basic auth.
+func setAuth(req *http.Request, cfg Config) {
+	switch {
+	case cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	case cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+cfg.APIKey)
+	case cfg.Username != "" || cfg.Password != "":
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+}
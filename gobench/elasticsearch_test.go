@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getEsVersion(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(`{"version" : {"number" : "7.11.1"}}`))
+		}))
+		t.Cleanup(srv.Close)
+		v, err := getEsVersion(context.Background(), Config{URL: srv.URL})
+		require.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, "7.11.1", v.String())
+	})
+	t.Run("success-auth", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, password, ok := r.BasicAuth()
+			require.True(t, ok)
+			assert.Equal(t, "myuser", user)
+			assert.Equal(t, "mypassword", password)
+			w.Write([]byte(`{"version" : {"number" : "7.11.1"}}`))
+		}))
+		t.Cleanup(srv.Close)
+		v, err := getEsVersion(context.Background(), Config{URL: srv.URL, Username: "myuser", Password: "mypassword"})
+		require.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, "7.11.1", v.String())
+	})
+	t.Run("success-bearer-token", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _, ok := r.BasicAuth()
+			assert.False(t, ok)
+			assert.Equal(t, "Bearer mytoken", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"version" : {"number" : "7.11.1"}}`))
+		}))
+		t.Cleanup(srv.Close)
+		v, err := getEsVersion(context.Background(), Config{URL: srv.URL, Username: "myuser", Password: "mypassword", BearerToken: "mytoken"})
+		require.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, "7.11.1", v.String())
+	})
+	t.Run("fail-401", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":{"root_cause":[{"type":"security_exception","reason":"missing authentication credentials for REST request [/]","header":{"WWW-Authenticate":["Basic realm=\"security\" charset=\"UTF-8\"","Bearer realm=\"security\"","ApiKey"]}}],"type":"security_exception","reason":"missing authentication credentials for REST request [/]","header":{"WWW-Authenticate":["Basic realm=\"security\" charset=\"UTF-8\"","Bearer realm=\"security\"","ApiKey"]}},"status":401}`))
+		}))
+		t.Cleanup(srv.Close)
+		v, err := getEsVersion(context.Background(), Config{URL: srv.URL})
+		assert.EqualError(t, err, "received unexpected 401 status code")
+		assert.Nil(t, v)
+	})
+}
+
+func Test_setAuth(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg      Config
+		expected string
+	}{
+		"none":         {cfg: Config{}, expected: ""},
+		"basic":        {cfg: Config{Username: "myuser", Password: "mypassword"}, expected: "Basic bXl1c2VyOm15cGFzc3dvcmQ="},
+		"api-key":      {cfg: Config{Username: "myuser", Password: "mypassword", APIKey: "mykey"}, expected: "ApiKey mykey"},
+		"bearer-token": {cfg: Config{Username: "myuser", Password: "mypassword", APIKey: "mykey", BearerToken: "mytoken"}, expected: "Bearer mytoken"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			setAuth(req, tc.cfg)
+			assert.Equal(t, tc.expected, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func Test_handleResponseLogsDebug(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"took":3,"errors":false,"items":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	resp, err := http.Post(srv.URL+"/_bulk", "application/x-ndjson", nil)
+	require.NoError(t, err)
+	require.NoError(t, handleResponse(resp, logger))
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "DEBUG", record["level"])
+	assert.Equal(t, "Elasticsearch response", record["msg"])
+	assert.Equal(t, "POST", record["method"])
+	assert.Equal(t, srv.URL+"/_bulk", record["url"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, map[string]interface{}{
+		"took":   float64(3),
+		"errors": false,
+		"items":  []interface{}{},
+	}, record["body"])
+}
@@ -0,0 +1,242 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hostFieldsOnce guards hostFields, which caches the fields added by
+// addHost, since they are the same for every document.
+var (
+	hostFieldsOnce sync.Once
+	hostFields     map[string]interface{}
+)
+
+// addHost adds fields describing the host to doc. The fields are read
+// once, and copied into each document.
+func addHost(doc map[string]interface{}) {
+	hostFieldsOnce.Do(func() {
+		hostFields = make(map[string]interface{})
+		readHost(hostFields)
+	})
+	for field, value := range hostFields {
+		doc[field] = copyValue(value)
+	}
+}
+
+// readHost adds fields describing the host to doc.
+func readHost(doc map[string]interface{}) {
+	if hostname, err := os.Hostname(); err == nil {
+		doc[FieldHostname] = hostname
+	}
+	if version := osVersion(runtime.GOOS); version != "" {
+		doc[FieldOSVersion] = version
+	}
+	doc[FieldNumCPU] = runtime.NumCPU()
+	if memTotal, ok := memTotalBytes(runtime.GOOS); ok {
+		doc[FieldMemTotalBytes] = memTotal
+	}
+	if runtime.GOOS == "linux" {
+		addContainer(hostRoot, doc)
+		addCPUFreq(hostRoot, doc)
+	}
+	addCloud(cloudProviders, doc)
+}
+
+// hostRoot is the root of the filesystem examined by addContainer, which
+// may be replaced in tests.
+var hostRoot = "/"
+
+// containerEnvVars are environment variables which indicate that the
+// process is running in a container.
+var containerEnvVars = []string{"container", "KUBERNETES_SERVICE_HOST"}
+
+// containerCgroupPatterns are substrings of cgroup paths which indicate
+// that the process is running in a container.
+var containerCgroupPatterns = []string{"docker", "kubepods", "containerd", "libpod", "lxc"}
+
+// addContainer records in doc whether the process appears to be running
+// in a container, and the CPU quota of its cgroup in CPUs, if any. The
+// filesystem is examined relative to root. Detection is best-effort.
+func addContainer(root string, doc map[string]interface{}) {
+	doc[FieldContainerized] = isContainerized(root)
+	if quota, ok := cgroupCPUQuota(root); ok {
+		doc[FieldCPUQuota] = quota
+	}
+}
+
+// cpufreqDir is the Linux sysfs directory describing the frequency
+// scaling of the first CPU, relative to the filesystem root.
+const cpufreqDir = "sys/devices/system/cpu/cpu0/cpufreq"
+
+// addCPUFreq adds the frequency scaling governor of the first CPU, e.g.
+// "performance" or "powersave", and its current frequency in kHz to doc.
+// The filesystem is examined relative to root. Fields are omitted if the
+// kernel does not expose them, as in many virtual machines.
+func addCPUFreq(root string, doc map[string]interface{}) {
+	if data, err := os.ReadFile(filepath.Join(root, cpufreqDir, "scaling_governor")); err == nil {
+		if governor := strings.TrimSpace(string(data)); governor != "" {
+			doc[FieldCPUGovernor] = governor
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(root, cpufreqDir, "scaling_cur_freq")); err == nil {
+		if freq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			doc[FieldCPUFreqKHz] = freq
+		}
+	}
+}
+
+func isContainerized(root string) bool {
+	for _, path := range []string{".dockerenv", "run/.containerenv"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err == nil {
+			return true
+		}
+	}
+	for _, key := range containerEnvVars {
+		if os.Getenv(key) != "" {
+			return true
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(root, "proc/self/cgroup"))
+	if err != nil {
+		return false
+	}
+	for _, pattern := range containerCgroupPatterns {
+		if strings.Contains(string(data), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// cgroupCPUQuota returns the CPU quota of the cgroup, in CPUs, from the
+// cgroup v2 cpu.max file or the cgroup v1 CFS quota and period.
+func cgroupCPUQuota(root string) (float64, bool) {
+	readFields := func(path string) []string {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			return nil
+		}
+		return strings.Fields(string(data))
+	}
+	quotaAndPeriod := func(quota, period string) (float64, bool) {
+		q, err := strconv.ParseFloat(quota, 64)
+		if err != nil || q <= 0 {
+			return 0, false
+		}
+		p, err := strconv.ParseFloat(period, 64)
+		if err != nil || p <= 0 {
+			return 0, false
+		}
+		return q / p, true
+	}
+
+	// cgroup v2: "<quota> <period>", where quota may be "max".
+	if fields := readFields("sys/fs/cgroup/cpu.max"); len(fields) == 2 {
+		return quotaAndPeriod(fields[0], fields[1])
+	}
+	// cgroup v1: a quota of -1 means unlimited.
+	quota := readFields("sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period := readFields("sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if len(quota) == 1 && len(period) == 1 {
+		return quotaAndPeriod(quota[0], period[0])
+	}
+	return 0, false
+}
+
+// meminfoPath is the path of the Linux meminfo file, which may be
+// replaced in tests.
+var meminfoPath = "/proc/meminfo"
+
+// memTotalBytes returns the total physical memory of the host running
+// the operating system goos.
+func memTotalBytes(goos string) (uint64, bool) {
+	switch goos {
+	case "linux":
+		f, err := os.Open(meminfoPath)
+		if err != nil {
+			return 0, false
+		}
+		defer f.Close()
+		return parseMeminfo(f)
+	case "darwin":
+		output, err := runCommand("", "sysctl", "-n", "hw.memsize")
+		if err != nil {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(string(output)), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// parseMeminfo returns the MemTotal value, in bytes, from the contents
+// of /proc/meminfo.
+func parseMeminfo(r io.Reader) (uint64, bool) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			n *= 1024
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// windowsVersionPattern matches the version in the output of "ver",
+// e.g. "Microsoft Windows [Version 10.0.19045.3803]".
+var windowsVersionPattern = regexp.MustCompile(`\[Version ([^\]]+)\]`)
+
+// osVersion returns the version of the operating system goos, or "" if
+// it cannot be determined.
+func osVersion(goos string) string {
+	switch goos {
+	case "linux":
+		if output, err := runCommand("", "uname", "-r"); err == nil {
+			return strings.TrimSpace(string(output))
+		}
+	case "darwin":
+		if output, err := runCommand("", "sw_vers", "-productVersion"); err == nil {
+			return strings.TrimSpace(string(output))
+		}
+	case "windows":
+		if output, err := runCommand("", "cmd", "/c", "ver"); err == nil {
+			if match := windowsVersionPattern.FindSubmatch(output); match != nil {
+				return string(match[1])
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_osVersion(t *testing.T) {
+	stubCommands(t, map[string]string{
+		"uname -r":                "6.5.0-1017-azure\n",
+		"sw_vers -productVersion": "14.2.1\n",
+		"cmd /c ver":              "\r\nMicrosoft Windows [Version 10.0.19045.3803]\r\n",
+	})
+	assert.Equal(t, "6.5.0-1017-azure", osVersion("linux"))
+	assert.Equal(t, "14.2.1", osVersion("darwin"))
+	assert.Equal(t, "10.0.19045.3803", osVersion("windows"))
+	assert.Equal(t, "", osVersion("plan9"))
+}
+
+func Test_osVersionCommandFailure(t *testing.T) {
+	stubCommands(t, nil)
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		assert.Equal(t, "", osVersion(goos), goos)
+	}
+}
+
+const sampleMeminfo = `MemTotal:       16318460 kB
+MemFree:         1234567 kB
+MemAvailable:    9876543 kB
+Buffers:          123456 kB
+`
+
+func Test_parseMeminfo(t *testing.T) {
+	n, ok := parseMeminfo(strings.NewReader(sampleMeminfo))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(16318460*1024), n)
+
+	_, ok = parseMeminfo(strings.NewReader("MemFree: 1234567 kB\n"))
+	assert.False(t, ok)
+	_, ok = parseMeminfo(strings.NewReader("MemTotal: lots kB\n"))
+	assert.False(t, ok)
+}
+
+func Test_memTotalBytes(t *testing.T) {
+	orig := meminfoPath
+	t.Cleanup(func() { meminfoPath = orig })
+	meminfoPath = filepath.Join(t.TempDir(), "meminfo")
+	require.NoError(t, os.WriteFile(meminfoPath, []byte(sampleMeminfo), 0600))
+	n, ok := memTotalBytes("linux")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(16318460*1024), n)
+
+	meminfoPath = filepath.Join(t.TempDir(), "missing")
+	_, ok = memTotalBytes("linux")
+	assert.False(t, ok)
+
+	stubCommands(t, map[string]string{"sysctl -n hw.memsize": "17179869184\n"})
+	n, ok = memTotalBytes("darwin")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(17179869184), n)
+}
+
+func Test_addHostNumCPU(t *testing.T) {
+	doc := make(map[string]interface{})
+	addHost(doc)
+	assert.Equal(t, runtime.NumCPU(), doc[FieldNumCPU])
+}
+
+// newHostRoot returns a temporary directory containing the given files,
+// keyed by their paths relative to the directory.
+func newHostRoot(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
+
+func Test_addContainer(t *testing.T) {
+	for _, key := range containerEnvVars {
+		t.Setenv(key, "")
+	}
+	for name, tc := range map[string]struct {
+		files    map[string]string
+		expected map[string]interface{}
+	}{
+		"host": {
+			files: map[string]string{
+				"proc/self/cgroup":      "0::/user.slice/user-1000.slice/session-1.scope\n",
+				"sys/fs/cgroup/cpu.max": "max 100000\n",
+			},
+			expected: map[string]interface{}{FieldContainerized: false},
+		},
+		"dockerenv": {
+			files:    map[string]string{".dockerenv": ""},
+			expected: map[string]interface{}{FieldContainerized: true},
+		},
+		"cgroup-v1": {
+			files: map[string]string{
+				"proc/self/cgroup":                    "12:cpu,cpuacct:/docker/0123456789abcdef\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":  "150000\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000\n",
+			},
+			expected: map[string]interface{}{FieldContainerized: true, FieldCPUQuota: 1.5},
+		},
+		"cgroup-v1-unlimited": {
+			files: map[string]string{
+				"proc/self/cgroup":                    "12:cpu,cpuacct:/kubepods/burstable/pod1\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":  "-1\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000\n",
+			},
+			expected: map[string]interface{}{FieldContainerized: true},
+		},
+		"cgroup-v2": {
+			files: map[string]string{
+				"run/.containerenv":     "",
+				"sys/fs/cgroup/cpu.max": "200000 100000\n",
+			},
+			expected: map[string]interface{}{FieldContainerized: true, FieldCPUQuota: 2.0},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc := make(map[string]interface{})
+			addContainer(newHostRoot(t, tc.files), doc)
+			assert.Equal(t, tc.expected, doc)
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+		doc := make(map[string]interface{})
+		addContainer(t.TempDir(), doc)
+		assert.Equal(t, map[string]interface{}{FieldContainerized: true}, doc)
+	})
+}
+
+func Test_addCPUFreq(t *testing.T) {
+	root := newHostRoot(t, map[string]string{
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_governor": "powersave\n",
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq": "2400000\n",
+	})
+	doc := make(map[string]interface{})
+	addCPUFreq(root, doc)
+	assert.Equal(t, map[string]interface{}{
+		FieldCPUGovernor: "powersave",
+		FieldCPUFreqKHz:  uint64(2400000),
+	}, doc)
+
+	root = newHostRoot(t, map[string]string{
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_governor": "performance\n",
+	})
+	doc = make(map[string]interface{})
+	addCPUFreq(root, doc)
+	assert.Equal(t, map[string]interface{}{FieldCPUGovernor: "performance"}, doc)
+
+	doc = make(map[string]interface{})
+	addCPUFreq(t.TempDir(), doc)
+	assert.Empty(t, doc)
+}
@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateILMPolicy returns an error if the ILM policy configured in cfg
+// cannot be applied. Rolling over requires a write alias, so without
+// cfg.Alias the policy instead deletes indices created from the index
+// template once they reach cfg.ILMMaxAge, which cannot be applied to a
+// single index nor limited by size.
+func ValidateILMPolicy(cfg Config) error {
+	if cfg.ILMPolicy == "" || cfg.Alias != "" {
+		return nil
+	}
+	if !usesTemplate(cfg) {
+		return errors.New("ILM policy requires an alias to roll over, or an index template to delete indices by age")
+	}
+	if cfg.ILMMaxSize != "" {
+		return errors.New("ILM max size requires an alias to roll over")
+	}
+	return nil
+}
+
+// ilmPhases returns the phases of the ILM policy: with cfg.Alias, a hot
+// phase which rolls over indices at cfg.ILMMaxAge and/or cfg.ILMMaxSize;
+// otherwise a delete phase which deletes indices at cfg.ILMMaxAge.
+func ilmPhases(cfg Config) map[string]interface{} {
+	if cfg.Alias == "" {
+		return map[string]interface{}{
+			"delete": map[string]interface{}{
+				"min_age": cfg.ILMMaxAge,
+				"actions": map[string]interface{}{
+					"delete": map[string]interface{}{},
+				},
+			},
+		}
+	}
+	rollover := make(map[string]interface{})
+	if cfg.ILMMaxAge != "" {
+		rollover["max_age"] = cfg.ILMMaxAge
+	}
+	if cfg.ILMMaxSize != "" {
+		rollover["max_size"] = cfg.ILMMaxSize
+	}
+	return map[string]interface{}{
+		"hot": map[string]interface{}{
+			"actions": map[string]interface{}{
+				"rollover": rollover,
+			},
+		},
+	}
+}
+
+// createILMPolicy creates or updates the ILM policy cfg.ILMPolicy, with
+// the phases returned by ilmPhases. Putting a policy is idempotent, so an
+// existing policy is simply updated.
+func createILMPolicy(ctx context.Context, cfg Config) error {
+	if err := ValidateILMPolicy(cfg); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]interface{}{
+		"policy": map[string]interface{}{
+			"phases": ilmPhases(cfg),
+		},
+	}); err != nil {
+		return err
+	}
+
+	policyURL := cfg.URL + "/_ilm/policy/" + url.PathEscape(cfg.ILMPolicy)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, policyURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	return handleResponse(resp, cfg.logger())
+}
@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordRequests returns a test server which records the method, path,
+// query and JSON body of each request, responding with an acknowledgement.
+func recordRequests(t *testing.T) (*httptest.Server, *[]recordedRequest) {
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var body map[string]interface{}
+		if len(data) > 0 {
+			require.NoError(t, json.Unmarshal(data, &body))
+		}
+		requests = append(requests, recordedRequest{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, body: body})
+		if r.Method == http.MethodHead {
+			// Report that aliases do not exist, so that they are created.
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+type recordedRequest struct {
+	method string
+	path   string
+	query  string
+	body   map[string]interface{}
+}
+
+func Test_createMappingILMPolicy(t *testing.T) {
+	srv, requests := recordRequests(t)
+	cfg := Config{
+		URL:        srv.URL,
+		Index:      "gobench",
+		Alias:      "gobench-write",
+		ILMPolicy:  "gobench-policy",
+		ILMMaxAge:  "30d",
+		ILMMaxSize: "50gb",
+	}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	require.Len(t, *requests, 3)
+
+	policy := (*requests)[0]
+	assert.Equal(t, http.MethodPut, policy.method)
+	assert.Equal(t, "/_ilm/policy/gobench-policy", policy.path)
+	assert.Equal(t, map[string]interface{}{
+		"policy": map[string]interface{}{
+			"phases": map[string]interface{}{
+				"hot": map[string]interface{}{
+					"actions": map[string]interface{}{
+						"rollover": map[string]interface{}{
+							"max_age":  "30d",
+							"max_size": "50gb",
+						},
+					},
+				},
+			},
+		},
+	}, policy.body)
+
+	assert.Equal(t, "/_alias/gobench-write", (*requests)[1].path)
+	index := (*requests)[2]
+	assert.Equal(t, "/gobench-000001", index.path)
+	assert.Equal(t, map[string]interface{}{
+		"index.lifecycle.name":           "gobench-policy",
+		"index.lifecycle.rollover_alias": "gobench-write",
+	}, index.body["settings"])
+	assert.Equal(t, map[string]interface{}{
+		"gobench-write": map[string]interface{}{"is_write_index": true},
+	}, index.body["aliases"])
+}
+
+func Test_createMappingILMPolicyTemplate(t *testing.T) {
+	srv, requests := recordRequests(t)
+	cfg := Config{
+		URL:         srv.URL,
+		Index:       "gobench",
+		UseTemplate: true,
+		ILMPolicy:   "gobench-policy",
+		ILMMaxAge:   "7d",
+	}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	require.Len(t, *requests, 2)
+
+	assert.Equal(t, map[string]interface{}{
+		"policy": map[string]interface{}{
+			"phases": map[string]interface{}{
+				"delete": map[string]interface{}{
+					"min_age": "7d",
+					"actions": map[string]interface{}{
+						"delete": map[string]interface{}{},
+					},
+				},
+			},
+		},
+	}, (*requests)[0].body)
+
+	template := (*requests)[1]
+	assert.Equal(t, "/_index_template/gobench", template.path)
+	assert.Equal(t, map[string]interface{}{
+		"index.lifecycle.name": "gobench-policy",
+	}, template.body["template"].(map[string]interface{})["settings"])
+}
+
+func Test_createMappingILMPolicyInvalid(t *testing.T) {
+	srv, requests := recordRequests(t)
+	cfg := Config{URL: srv.URL, Index: "gobench", ILMPolicy: "gobench-policy", ILMMaxAge: "7d"}
+	err := createMapping(context.Background(), cfg, nil)
+	assert.EqualError(t, err, "error creating ILM policy: ILM policy requires an alias to roll over, or an index template to delete indices by age")
+
+	cfg.UseTemplate = true
+	cfg.ILMMaxSize = "50gb"
+	err = createMapping(context.Background(), cfg, nil)
+	assert.EqualError(t, err, "error creating ILM policy: ILM max size requires an alias to roll over")
+	assert.Empty(t, *requests)
+}
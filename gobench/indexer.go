@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package gobench parses the output of "go test -bench", and indexes the
+// benchmark results into Elasticsearch.
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// Indexer is an Output which indexes benchmark result documents into
+// Elasticsearch, sending them in bulk requests of approximately
+// Config.BulkMaxBytes.
+type Indexer struct {
+	cfg       Config
+	esVersion *semver.Version
+	bulk      *bulkWriter
+	encoder   *json.Encoder
+	ids       idSequence
+}
+
+// NewIndexer returns an Indexer which indexes documents into the
+// Elasticsearch cluster and index described by cfg, creating the index
+// and its mapping if necessary.
+//
+// The Elasticsearch version is resolved up front, as it determines whether
+// type names are required in the mapping and bulk actions. This also
+// checks that Elasticsearch is reachable and accepts the configured
+// credentials, so that callers fail fast rather than after consuming the
+// benchmark output. If cfg.Serverless is set, or the cluster is detected
+// to be Elasticsearch Serverless, the latest version is assumed instead.
+func NewIndexer(ctx context.Context, cfg Config) (*Indexer, error) {
+	esURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	var esVersion *semver.Version
+	if !cfg.Serverless {
+		info, err := getEsInfo(ctx, cfg)
+		if err != nil {
+			var statusErr *esStatusError
+			if !errors.As(err, &statusErr) ||
+				statusErr.statusCode == http.StatusUnauthorized ||
+				statusErr.statusCode == http.StatusForbidden {
+				return nil, errors.Wrapf(err, "error connecting to Elasticsearch at %s", esURL.Redacted())
+			}
+			cfg.logger().Warn("error fetching Elasticsearch version, assuming latest", "error", err)
+		}
+		if info.serverless {
+			cfg.logger().Debug("detected Elasticsearch Serverless")
+			cfg.Serverless = true
+		} else {
+			esVersion = info.version
+		}
+	}
+	if err := createMapping(ctx, cfg, esVersion); err != nil {
+		return nil, errors.Wrap(err, "error creating/updating mapping")
+	}
+	bulk := &bulkWriter{ctx: ctx, cfg: cfg, esURL: esURL}
+	bulk.start()
+	return &Indexer{
+		cfg:       cfg,
+		esVersion: esVersion,
+		bulk:      bulk,
+		encoder:   json.NewEncoder(bulk),
+		ids:       newIDSequence(cfg),
+	}, nil
+}
+
+// Version returns the version of Elasticsearch, or nil if it could not
+// be determined and the latest version is assumed.
+func (ix *Indexer) Version() *semver.Version {
+	return ix.esVersion
+}
+
+// Write adds doc to the current bulk request, sending the request if it
+// has reached the configured maximum size. Errors from bulk requests are
+// returned by Flush.
+func (ix *Indexer) Write(doc Document) error {
+	if err := encodeBulkAction(ix.encoder, doc, ix.cfg, ix.esVersion, ix.ids); err != nil {
+		return err
+	}
+	ix.bulk.flushIfFull()
+	return nil
+}
+
+// UploadFile adds the bulk actions in the named NDJSON file, as written by
+// EncodeBulkAction, to the bulk requests.
+func (ix *Indexer) UploadFile(path string) error {
+	return uploadBulkFile(path, ix.bulk)
+}
+
+// EndRequest sends the documents written since the previous bulk request
+// in a request of their own, and waits for all requests to complete, so
+// that documents written afterwards are sent in new requests. Errors from
+// the requests are returned by Flush.
+func (ix *Indexer) EndRequest() {
+	ix.bulk.drain()
+	ix.bulk.start()
+}
+
+// Flush sends any remaining documents, and returns an error describing
+// all of the bulk requests that failed. If Config.AutoRollover is set and
+// all documents were indexed, the alias is then rolled over if its
+// conditions are met.
+func (ix *Indexer) Flush() error {
+	if err := ix.bulk.close(); err != nil {
+		return errors.Wrap(err, "error executing bulk updates")
+	}
+	if ix.cfg.AutoRollover {
+		if err := rolloverAlias(ix.bulk.ctx, ix.cfg); err != nil {
+			return errors.Wrapf(err, "error rolling over alias %s", ix.cfg.Alias)
+		}
+	}
+	return nil
+}
+
+// Indexed returns the number of documents successfully indexed.
+func (ix *Indexer) Indexed() int {
+	indexed, _ := ix.bulk.counts()
+	return indexed
+}
+
+// Failed returns the number of documents which failed to be indexed.
+func (ix *Indexer) Failed() int {
+	_, failed := ix.bulk.counts()
+	return failed
+}
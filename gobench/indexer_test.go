@@ -0,0 +1,145 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_Indexer(t *testing.T) {
+	var actions, docs []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/gobench":
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			decoder := json.NewDecoder(r.Body)
+			for {
+				var action, doc map[string]interface{}
+				if err := decoder.Decode(&action); err == io.EOF {
+					break
+				}
+				require.NoError(t, decoder.Decode(&doc))
+				actions = append(actions, action)
+				docs = append(docs, doc)
+			}
+			w.Write([]byte(`{"took":1,"errors":false}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	indexer, err := NewIndexer(context.Background(), Config{URL: srv.URL, Index: "gobench"})
+	require.NoError(t, err)
+	assert.Equal(t, "8.1.0", indexer.Version().String())
+
+	b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+	timestamp := time.Date(2024, time.January, 15, 23, 30, 0, 0, time.UTC)
+	doc := NewDocument(b, "example.com/foo", "linux", "amd64", "", map[string]string{"branch": "main"}, timestamp)
+	require.NoError(t, indexer.Write(doc))
+	require.NoError(t, indexer.Flush())
+	assert.Equal(t, 1, indexer.Indexed())
+	assert.Equal(t, 0, indexer.Failed())
+
+	require.Len(t, docs, 1)
+	assert.Equal(t, map[string]interface{}{"index": map[string]interface{}{"_index": "gobench"}}, actions[0])
+	assert.Equal(t, "BenchmarkFoo", docs[0][FieldName])
+	assert.Equal(t, "example.com/foo", docs[0][FieldPkg])
+	assert.Equal(t, 12.5, docs[0][FieldNSPerOp])
+	assert.Equal(t, 8.0, docs[0][FieldGOMAXPROCS])
+	assert.Equal(t, map[string]interface{}{"branch": "main"}, docs[0][FieldTags])
+	assert.Equal(t, "2024-01-15T23:30:00Z", docs[0][FieldExecutedAt])
+}
+
+func Test_NewIndexerUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := NewIndexer(context.Background(), Config{URL: srv.URL, Index: "gobench"})
+	assert.EqualError(t, err, "error connecting to Elasticsearch at "+srv.URL+": received unexpected 401 status code")
+}
+
+func Test_NewIndexerServerless(t *testing.T) {
+	for name, explicit := range map[string]bool{"detected": false, "explicit": true} {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			var index, action map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/":
+					w.Write([]byte(`{"name":"serverless","cluster_name":"abc123","version":{"number":"8.11.0","build_flavor":"serverless"},"tagline":"You Know, for Search"}`))
+				case r.Method == http.MethodPut && r.URL.Path == "/gobench":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&index))
+					w.Write([]byte(`{"acknowledged":true}`))
+				case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&action))
+					w.Write([]byte(`{"took":1,"errors":false}`))
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			replicas := 1
+			indexer, err := NewIndexer(context.Background(), Config{
+				URL:        srv.URL,
+				Index:      "gobench",
+				Serverless: explicit,
+				Shards:     2,
+				Replicas:   &replicas,
+				ILMPolicy:  "gobench-policy",
+				ILMMaxAge:  "30d",
+			})
+			require.NoError(t, err)
+			assert.Nil(t, indexer.Version())
+
+			b := Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, Measured: parse.NsPerOp}}
+			doc := NewDocument(b, "example.com/foo", "linux", "amd64", "", nil, time.Now())
+			require.NoError(t, indexer.Write(doc))
+			require.NoError(t, indexer.Flush())
+
+			expectedPaths := []string{"GET /", "PUT /gobench", "POST /_bulk"}
+			if explicit {
+				expectedPaths = expectedPaths[1:]
+			}
+			assert.Equal(t, expectedPaths, paths)
+			assert.Contains(t, index, "mappings")
+			assert.NotContains(t, index, "settings")
+			assert.NotContains(t, index["mappings"], "_doc")
+			assert.Equal(t, map[string]interface{}{"index": map[string]interface{}{"_index": "gobench"}}, action)
+		})
+	}
+}
@@ -0,0 +1,450 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+type fieldProperties map[string]interface{}
+
+const (
+	FieldExecutedAt        = "executed_at"
+	FieldName              = "name"
+	FieldIterations        = "iterations"
+	FieldPkg               = "pkg"
+	FieldHostname          = "hostname"
+	FieldGoVersion         = "go_version"
+	FieldOSVersion         = "os_version"
+	FieldNumCPU            = "num_cpu"
+	FieldMemTotalBytes     = "mem_total_bytes"
+	FieldContainerized     = "containerized"
+	FieldCPUQuota          = "cpu_quota"
+	FieldCPUGovernor       = "cpu_governor"
+	FieldCPUFreqKHz        = "cpu_freq_khz"
+	FieldGOOS              = "goos"
+	FieldGOARCH            = "goarch"
+	FieldCPU               = "cpu"
+	FieldNSPerOp           = "ns_per_op"
+	FieldTotalNS           = "total_ns"
+	FieldMBPerS            = "mb_per_s"
+	FieldAllocedBytesPerOp = "alloced_bytes_per_op"
+	FieldAllocsPerOp       = "allocs_per_op"
+	FieldGOMAXPROCS        = "gomaxprocs"
+	FieldNSPerOpStats      = "ns_per_op_stats"
+	FieldFullName          = "full_name"
+	FieldParams            = "params"
+	FieldSegments          = "segments"
+	FieldTags              = "tags"
+	FieldBenchtime         = "benchtime"
+
+	// FieldGobenchVersion holds the version of the tool which produced
+	// the document, such as the gobench command.
+	FieldGobenchVersion = "gobench_version"
+
+	// FieldRunID holds a UUID identifying the invocation of gobench which
+	// produced the document, shared by all of its documents.
+	FieldRunID = "run_id"
+
+	FieldGit              = "git"
+	FieldGitCommit        = "commit"
+	FieldGitSubject       = "subject"
+	FieldGitCommitter     = "committer"
+	FieldGitCommitterDate = "date"
+	FieldGitBranch        = "branch"
+	FieldGitDirty         = "dirty"
+	FieldGitAuthor        = "author"
+	FieldGitAuthorName    = "name"
+	FieldGitAuthorEmail   = "email"
+	FieldGitAuthorDate    = "date"
+	FieldGitRemote        = "remote"
+
+	// FieldHg holds the same fields as FieldGit, for Mercurial repositories.
+	FieldHg = "hg"
+
+	FieldCI            = "ci"
+	FieldCIProvider    = "provider"
+	FieldCIBuildID     = "build_id"
+	FieldCIJobURL      = "job_url"
+	FieldCICommit      = "commit"
+	FieldCIBranch      = "branch"
+	FieldCIRepository  = "repository"
+	FieldCIPullRequest = "pull_request"
+
+	FieldCloud             = "cloud"
+	FieldCloudProvider     = "provider"
+	FieldCloudInstanceType = "instance_type"
+	FieldCloudRegion       = "region"
+	FieldCloudZone         = "zone"
+
+	FieldExtraMetrics = "extra_metrics"
+
+	// FieldRaw holds the line of benchmark output from which the result
+	// was parsed, when DocumentOptions.StoreRaw is set.
+	FieldRaw = "raw"
+
+	// FieldBuildSettings holds the build settings of the test binary, as
+	// returned by ReadBuildSettings.
+	FieldBuildSettings = "build_settings"
+)
+
+var (
+	esFieldProperties = map[string]fieldProperties{
+		FieldExecutedAt:        {"type": "date"},
+		FieldName:              {"type": "keyword"},
+		FieldIterations:        {"type": "long"},
+		FieldPkg:               {"type": "keyword"},
+		FieldHostname:          {"type": "keyword"},
+		FieldGoVersion:         {"type": "keyword"},
+		FieldOSVersion:         {"type": "keyword"},
+		FieldNumCPU:            {"type": "long"},
+		FieldMemTotalBytes:     {"type": "long"},
+		FieldContainerized:     {"type": "boolean"},
+		FieldCPUQuota:          {"type": "double"},
+		FieldCPUGovernor:       {"type": "keyword"},
+		FieldCPUFreqKHz:        {"type": "long"},
+		FieldGOOS:              {"type": "keyword"},
+		FieldGOARCH:            {"type": "keyword"},
+		FieldCPU:               {"type": "keyword"},
+		FieldNSPerOp:           {"type": "double"},
+		FieldTotalNS:           {"type": "double"},
+		FieldMBPerS:            {"type": "double"},
+		FieldAllocedBytesPerOp: {"type": "long"},
+		FieldAllocsPerOp:       {"type": "long"},
+		FieldGOMAXPROCS:        {"type": "long"},
+		FieldNSPerOpStats: {
+			"properties": map[string]fieldProperties{
+				"count":  {"type": "long"},
+				"min":    {"type": "double"},
+				"median": {"type": "double"},
+				"max":    {"type": "double"},
+				"stddev": {"type": "double"},
+			},
+		},
+		FieldFullName:       {"type": "keyword"},
+		FieldParams:         {"type": "object"},
+		FieldSegments:       {"type": "keyword"},
+		FieldTags:           {"type": "object"},
+		FieldBenchtime:      {"type": "keyword"},
+		FieldGobenchVersion: {"type": "keyword"},
+		FieldRunID:          {"type": "keyword"},
+		// The raw line is kept for troubleshooting, not searching.
+		FieldRaw:           {"type": "text", "index": false},
+		FieldBuildSettings: {"type": "object"},
+		FieldGit:           {"properties": vcsFieldProperties},
+		FieldHg:            {"properties": vcsFieldProperties},
+		FieldCI: {
+			"properties": map[string]fieldProperties{
+				FieldCIProvider:    {"type": "keyword"},
+				FieldCIBuildID:     {"type": "keyword"},
+				FieldCIJobURL:      {"type": "keyword"},
+				FieldCICommit:      {"type": "keyword"},
+				FieldCIBranch:      {"type": "keyword"},
+				FieldCIRepository:  {"type": "keyword"},
+				FieldCIPullRequest: {"type": "keyword"},
+			},
+		},
+		FieldCloud: {
+			"properties": map[string]fieldProperties{
+				FieldCloudProvider:     {"type": "keyword"},
+				FieldCloudInstanceType: {"type": "keyword"},
+				FieldCloudRegion:       {"type": "keyword"},
+				FieldCloudZone:         {"type": "keyword"},
+			},
+		},
+	}
+	vcsFieldProperties = map[string]fieldProperties{
+		FieldGitCommit:  {"type": "text"},
+		FieldGitSubject: {"type": "text"},
+		FieldGitBranch:  {"type": "keyword"},
+		FieldGitDirty:   {"type": "boolean"},
+		FieldGitRemote:  {"type": "keyword"},
+		FieldGitCommitter: {
+			"properties": map[string]fieldProperties{
+				FieldGitCommitterDate: {"type": "date"},
+			},
+		},
+		FieldGitAuthor: {
+			"properties": map[string]fieldProperties{
+				FieldGitAuthorName:  {"type": "keyword"},
+				FieldGitAuthorEmail: {"type": "keyword"},
+				FieldGitAuthorDate:  {"type": "date"},
+			},
+		},
+	}
+	esExtraMetricsDynamicTemplate = map[string]interface{}{
+		FieldExtraMetrics: map[string]interface{}{
+			"path_match": "extra_metrics.*",
+			"mapping": map[string]string{
+				"type": "float",
+			},
+		},
+	}
+	esParamsDynamicTemplate = map[string]interface{}{
+		FieldParams: map[string]interface{}{
+			"path_match": "params.*",
+			"mapping": map[string]string{
+				"type": "keyword",
+			},
+		},
+	}
+	esTagsDynamicTemplate = map[string]interface{}{
+		FieldTags: map[string]interface{}{
+			"path_match": "tags.*",
+			"mapping": map[string]string{
+				"type": "keyword",
+			},
+		},
+	}
+	esBuildSettingsDynamicTemplate = map[string]interface{}{
+		FieldBuildSettings: map[string]interface{}{
+			"path_match": "build_settings.*",
+			"mapping": map[string]string{
+				"type": "keyword",
+			},
+		},
+	}
+)
+
+// typeNames records where the "_doc" mapping type, which was removed from
+// Elasticsearch in stages, is named in requests to a given version.
+type typeNames struct {
+	// mappings is true if mappings must be nested under the type name,
+	// as required before 7.0.0.
+	mappings bool
+
+	// actions is true if bulk actions name the type, which is required
+	// before 7.0.0 and accepted before 8.0.0.
+	actions bool
+}
+
+// esTypeNames returns the typeNames for esVersion. It is the single
+// source of the decision for both the mappings and bulk actions, so that
+// they cannot disagree. A nil esVersion is treated as the latest version
+// of Elasticsearch, which has no type names.
+func esTypeNames(esVersion *semver.Version) typeNames {
+	if esVersion == nil {
+		return typeNames{}
+	}
+	return typeNames{
+		mappings: esVersion.LT(semver.MustParse("7.0.0")),
+		actions:  esVersion.LT(semver.MustParse("8.0.0")),
+	}
+}
+
+// createMapping creates the index with the benchmark field mappings,
+// or an index template if cfg.UseTemplate, cfg.PerPackageIndex or
+// cfg.IndexFromTag is set or the index name contains date patterns.
+// A nil esVersion is treated as the latest version of Elasticsearch.
+func createMapping(ctx context.Context, cfg Config, esVersion *semver.Version) error {
+	if cfg.Serverless && (cfg.Shards > 0 || cfg.Replicas != nil || cfg.ILMPolicy != "") {
+		cfg.logger().Warn("ignoring shards, replicas and ILM policy, which are not supported by Elasticsearch Serverless")
+		cfg.Shards, cfg.Replicas, cfg.ILMPolicy = 0, nil, ""
+	}
+	if cfg.ILMPolicy != "" {
+		if err := createILMPolicy(ctx, cfg); err != nil {
+			return errors.Wrap(err, "error creating ILM policy")
+		}
+	}
+	if cfg.Alias != "" {
+		if usesTemplate(cfg) {
+			if err := createIndexTemplate(ctx, cfg, esVersion); err != nil {
+				return err
+			}
+		}
+		return bootstrapAlias(ctx, cfg, esVersion)
+	}
+	if usesTemplate(cfg) {
+		return createIndexTemplate(ctx, cfg, esVersion)
+	}
+	includeTypeName := esTypeNames(esVersion).mappings
+
+	index := map[string]interface{}{"mappings": esMappings(includeTypeName, cfg.MappingOverrides)}
+	if settings := esIndexSettings(cfg); settings != nil {
+		index["settings"] = settings
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(index); err != nil {
+		return err
+	}
+
+	mappingURL := cfg.URL + "/" + cfg.Index
+	if cfg.WaitForActiveShards != "" {
+		mappingURL += "?" + url.Values{"wait_for_active_shards": {cfg.WaitForActiveShards}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, mappingURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	if err := handleResponse(resp, cfg.logger()); err != nil {
+		esErr, ok := err.(*esError)
+		if ok && esErr.Type == exceptionResourceAlreadyExists {
+			cfg.logger().Debug("index already exists, updating mapping", "index", cfg.Index)
+			return updateMapping(ctx, cfg, includeTypeName)
+		}
+		return err
+	}
+	return nil
+}
+
+// esIndexSettings returns the settings for the index or index template,
+// or nil if there are none.
+func esIndexSettings(cfg Config) map[string]interface{} {
+	settings := make(map[string]interface{})
+	if cfg.Shards > 0 {
+		settings["index.number_of_shards"] = cfg.Shards
+	}
+	if cfg.Replicas != nil {
+		settings["index.number_of_replicas"] = *cfg.Replicas
+	}
+	if cfg.ILMPolicy != "" {
+		settings["index.lifecycle.name"] = cfg.ILMPolicy
+		if cfg.Alias != "" {
+			settings["index.lifecycle.rollover_alias"] = cfg.Alias
+		}
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	return settings
+}
+
+// usesTemplate reports whether the mappings for cfg are installed in an
+// index template, from which indices are created as documents are
+// indexed, rather than on an index created up front.
+func usesTemplate(cfg Config) bool {
+	return cfg.UseTemplate || cfg.PerPackageIndex || cfg.IndexFromTag != "" || IsIndexPattern(cfg.Index)
+}
+
+// updateMapping puts the benchmark field mappings on the existing index,
+// so that fields added since the index was created are mapped. Changes
+// to the types of existing fields are rejected by Elasticsearch.
+func updateMapping(ctx context.Context, cfg Config, includeTypeName bool) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(esMappings(false, cfg.MappingOverrides)); err != nil {
+		return err
+	}
+	mappingURL := cfg.URL + "/" + cfg.Index + "/_mapping"
+	if includeTypeName {
+		mappingURL += "/_doc"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, mappingURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	if err := handleResponse(resp, cfg.logger()); err != nil {
+		return errors.Wrapf(err, "error updating mapping of index %q", cfg.Index)
+	}
+	return nil
+}
+
+// esMappings returns the mappings for benchmark documents, with
+// overrides deep-merged into the field properties as by mergeProperties,
+// nested under the "_doc" type name if includeTypeName is true.
+func esMappings(includeTypeName bool, overrides map[string]interface{}) map[string]interface{} {
+	var properties interface{} = esFieldProperties
+	if len(overrides) > 0 {
+		base, _ := asObject(esFieldProperties)
+		properties = mergeProperties(base, overrides)
+	}
+	mappings := map[string]interface{}{
+		"properties": properties,
+		"dynamic_templates": []interface{}{
+			esExtraMetricsDynamicTemplate,
+			esParamsDynamicTemplate,
+			esTagsDynamicTemplate,
+			esBuildSettingsDynamicTemplate,
+		},
+	}
+	if includeTypeName {
+		mappings = map[string]interface{}{"_doc": mappings}
+	}
+	return mappings
+}
+
+// ParseMappingOverrides parses a JSON object whose "properties" object
+// holds field mappings to be merged into the benchmark field mappings,
+// returning the properties for Config.MappingOverrides.
+func ParseMappingOverrides(data []byte) (map[string]interface{}, error) {
+	var overrides struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, err
+	}
+	if overrides.Properties == nil {
+		return nil, errors.New(`missing "properties" object`)
+	}
+	return overrides.Properties, nil
+}
+
+// mergeProperties returns a copy of base with overrides deep-merged into
+// it: objects present in both are merged recursively, and any other value
+// in overrides, including an object replacing a non-object, wins over
+// that in base. Neither base nor overrides is modified.
+func mergeProperties(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if override, ok := value.(map[string]interface{}); ok {
+			if existing, ok := asObject(merged[key]); ok {
+				merged[key] = mergeProperties(existing, override)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// asObject returns v as a map[string]interface{} if it is a JSON object,
+// as decoded from JSON or as declared in esFieldProperties.
+func asObject(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case fieldProperties:
+		return v, true
+	case map[string]fieldProperties:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			object[key] = value
+		}
+		return object, true
+	}
+	return nil, false
+}
@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// existingIndexServer returns a test server for which the "gobench" index
+// already exists, recording the path and body of each mapping update and
+// responding to it with the given status and body.
+func existingIndexServer(t *testing.T, status int, response string) (*httptest.Server, *[]recordedRequest) {
+	var updates []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/gobench":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"resource_already_exists_exception","reason":"index [gobench] already exists"}}`))
+		case r.Method == http.MethodPut:
+			data, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &body))
+			updates = append(updates, recordedRequest{method: r.Method, path: r.URL.Path, body: body})
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &updates
+}
+
+func Test_createMappingExistingIndex(t *testing.T) {
+	srv, updates := existingIndexServer(t, http.StatusOK, `{"acknowledged":true}`)
+	cfg := Config{URL: srv.URL, Index: "gobench"}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+
+	require.Len(t, *updates, 1)
+	update := (*updates)[0]
+	assert.Equal(t, "/gobench/_mapping", update.path)
+	expected, err := json.Marshal(esMappings(false, nil))
+	require.NoError(t, err)
+	var expectedBody map[string]interface{}
+	require.NoError(t, json.Unmarshal(expected, &expectedBody))
+	assert.Equal(t, expectedBody, update.body)
+	assert.Contains(t, update.body["properties"], FieldFullName)
+}
+
+func Test_createMappingExistingIndexTypeName(t *testing.T) {
+	srv, updates := existingIndexServer(t, http.StatusOK, `{"acknowledged":true}`)
+	cfg := Config{URL: srv.URL, Index: "gobench"}
+	require.NoError(t, createMapping(context.Background(), cfg, &semver.Version{Major: 6, Minor: 8}))
+
+	require.Len(t, *updates, 1)
+	assert.Equal(t, "/gobench/_mapping/_doc", (*updates)[0].path)
+	assert.Contains(t, (*updates)[0].body, "properties")
+}
+
+func Test_createMappingExistingIndexConflict(t *testing.T) {
+	srv, _ := existingIndexServer(t, http.StatusBadRequest,
+		`{"error":{"type":"illegal_argument_exception","reason":"mapper [ns_per_op] cannot be changed from type [long] to [float]"}}`,
+	)
+	cfg := Config{URL: srv.URL, Index: "gobench"}
+	err := createMapping(context.Background(), cfg, nil)
+	assert.EqualError(t, err, `error updating mapping of index "gobench": mapper [ns_per_op] cannot be changed from type [long] to [float]`)
+}
+
+func Test_esTypeNamesMappingAndAction(t *testing.T) {
+	doc := Document{FieldName: "BenchmarkFoo"}
+	for version, expected := range map[string]typeNames{
+		"6.8.0":  {mappings: true, actions: true},
+		"7.11.1": {mappings: false, actions: true},
+		"8.0.0":  {mappings: false, actions: false},
+	} {
+		esVersion := semver.MustParse(version)
+
+		srv, requests := recordRequests(t)
+		require.NoError(t, createMapping(context.Background(), Config{URL: srv.URL, Index: "gobench"}, &esVersion))
+		require.Len(t, *requests, 1)
+		mappings := (*requests)[0].body["mappings"].(map[string]interface{})
+		_, typedMappings := mappings["_doc"]
+
+		var buf bytes.Buffer
+		require.NoError(t, EncodeBulkAction(json.NewEncoder(&buf), doc, Config{Index: "gobench"}, &esVersion))
+		var action struct {
+			Index map[string]interface{} `json:"index"`
+		}
+		require.NoError(t, json.NewDecoder(&buf).Decode(&action))
+		_, typedAction := action.Index["_type"]
+
+		assert.Equal(t, expected, esTypeNames(&esVersion), version)
+		assert.Equal(t, expected.mappings, typedMappings, version)
+		assert.Equal(t, expected.actions, typedAction, version)
+		// An index created with a type name must be written to with it.
+		assert.True(t, !typedMappings || typedAction, version)
+	}
+	assert.Equal(t, typeNames{}, esTypeNames(nil))
+}
+
+func Test_createMappingWaitForActiveShards(t *testing.T) {
+	srv, requests := recordRequests(t)
+	cfg := Config{URL: srv.URL, Index: "gobench", WaitForActiveShards: "all"}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	require.Len(t, *requests, 1)
+	assert.Equal(t, "/gobench", (*requests)[0].path)
+	assert.Equal(t, "wait_for_active_shards=all", (*requests)[0].query)
+
+	// Index templates do not create indices, so the bulk requests wait
+	// for the shards instead.
+	srv, requests = recordRequests(t)
+	cfg = Config{URL: srv.URL, Index: "gobench", UseTemplate: true, WaitForActiveShards: "2"}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	require.Len(t, *requests, 1)
+	assert.Equal(t, "/_index_template/gobench", (*requests)[0].path)
+	assert.Empty(t, (*requests)[0].query)
+}
+
+func Test_createMappingShardsReplicas(t *testing.T) {
+	replicas := 0
+	for _, useTemplate := range []bool{false, true} {
+		srv, requests := recordRequests(t)
+		cfg := Config{URL: srv.URL, Index: "gobench", UseTemplate: useTemplate, Shards: 1, Replicas: &replicas}
+		require.NoError(t, createMapping(context.Background(), cfg, nil))
+		require.Len(t, *requests, 1)
+
+		body := (*requests)[0].body
+		if useTemplate {
+			body = body["template"].(map[string]interface{})
+		}
+		assert.Equal(t, map[string]interface{}{
+			"index.number_of_shards":   float64(1),
+			"index.number_of_replicas": float64(0),
+		}, body["settings"], "useTemplate=%v", useTemplate)
+	}
+
+	// Without them, the cluster defaults apply.
+	srv, requests := recordRequests(t)
+	require.NoError(t, createMapping(context.Background(), Config{URL: srv.URL, Index: "gobench"}, nil))
+	require.Len(t, *requests, 1)
+	assert.NotContains(t, (*requests)[0].body, "settings")
+}
+
+func Test_esMappingsOverrides(t *testing.T) {
+	overrides, err := ParseMappingOverrides([]byte(`{"properties": {
+		"name": {"fields": {"text": {"type": "text"}}},
+		"hostname": {"ignore_above": 256},
+		"git": {"properties": {"subject": {"type": "match_only_text"}}},
+		"team": {"type": "keyword"}
+	}}`))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(esMappings(false, overrides))
+	require.NoError(t, err)
+	var mappings struct {
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(data, &mappings))
+	properties := mappings.Properties
+	assert.Equal(t, map[string]interface{}{
+		"type":   "keyword",
+		"fields": map[string]interface{}{"text": map[string]interface{}{"type": "text"}},
+	}, properties[FieldName])
+	assert.Equal(t, map[string]interface{}{"type": "keyword", "ignore_above": 256.0}, properties[FieldHostname])
+	assert.Equal(t, map[string]interface{}{"type": "keyword"}, properties["team"])
+
+	git := properties[FieldGit]["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "match_only_text"}, git[FieldGitSubject])
+	assert.Equal(t, map[string]interface{}{"type": "keyword"}, git[FieldGitBranch])
+	assert.Equal(t, map[string]interface{}{"type": "date"}, properties[FieldExecutedAt])
+
+	// The built-in mappings are not modified.
+	assert.Equal(t, fieldProperties{"type": "keyword"}, esFieldProperties[FieldName])
+	assert.Equal(t, fieldProperties{"type": "text"}, vcsFieldProperties[FieldGitSubject])
+}
+
+func Test_ParseMappingOverridesMissingProperties(t *testing.T) {
+	_, err := ParseMappingOverrides([]byte(`{"name": {"type": "text"}}`))
+	assert.EqualError(t, err, `missing "properties" object`)
+}
@@ -0,0 +1,58 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/blang/semver"
+)
+
+// Output is a destination for benchmark result documents.
+type Output interface {
+	// Write writes doc to the output. Implementations may buffer
+	// documents until Flush is called.
+	Write(doc Document) error
+
+	// Flush writes any buffered documents, after all documents
+	// have been written.
+	Flush() error
+}
+
+// NewBulkOutput returns an Output which writes documents to w as
+// Elasticsearch bulk actions, as sent by Indexer, for later upload. A nil
+// esVersion is treated as the latest version of Elasticsearch.
+func NewBulkOutput(w io.Writer, cfg Config, esVersion *semver.Version) Output {
+	return bulkOutput{encoder: json.NewEncoder(w), cfg: cfg, esVersion: esVersion, ids: newIDSequence(cfg)}
+}
+
+type bulkOutput struct {
+	encoder   *json.Encoder
+	cfg       Config
+	esVersion *semver.Version
+	ids       idSequence
+}
+
+func (o bulkOutput) Write(doc Document) error {
+	return encodeBulkAction(o.encoder, doc, o.cfg, o.esVersion, o.ids)
+}
+
+func (bulkOutput) Flush() error {
+	return nil
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// Result is a benchmark result parsed by a Parser, along with the values
+// of the headers which preceded it.
+type Result struct {
+	Benchmark
+	Pkg    string
+	GOOS   string
+	GOARCH string
+	CPU    string
+}
+
+// Parser parses the output of "go test -bench" line by line. The pkg,
+// goos, goarch and cpu headers apply to the results that follow them.
+// The zero value is ready to use.
+type Parser struct {
+	// Pkg, GOOS, GOARCH and CPU hold the values of the most recent
+	// headers. Pkg may also be set by the caller, e.g. from the package
+	// of a "go test -json" event.
+	Pkg    string
+	GOOS   string
+	GOARCH string
+	CPU    string
+
+	// Lines counts the lines parsed, Benchmarks the benchmark results,
+	// and Errors the lines which look like benchmark results but could
+	// not be parsed.
+	Lines      int
+	Benchmarks int
+	Errors     int
+
+	// Failed is set if the output reports that a test, benchmark or
+	// build failed, with a "FAIL" trailer line or a "--- FAIL:" line.
+	// The results of such a run may be incomplete.
+	Failed bool
+
+	// OnError, if non-nil, is called with the line number and text of
+	// each line which looks like a benchmark result but could not be
+	// parsed, e.g. because the output was truncated.
+	OnError func(lineNum int, line string, err error)
+}
+
+// ParseLine parses a single line of output, returning the benchmark
+// result it holds, or false if it holds none.
+func (p *Parser) ParseLine(line string) (Result, bool) {
+	p.Lines++
+	switch {
+	case strings.HasPrefix(line, "pkg:"):
+		p.Pkg = strings.TrimSpace(line[len("pkg:"):])
+	case strings.HasPrefix(line, "goos:"):
+		p.GOOS = strings.TrimSpace(line[len("goos:"):])
+	case strings.HasPrefix(line, "goarch:"):
+		p.GOARCH = strings.TrimSpace(line[len("goarch:"):])
+	case strings.HasPrefix(line, "cpu:"):
+		p.CPU = strings.TrimSpace(line[len("cpu:"):])
+	case isTestOutputLine(line):
+		if isTestFailureLine(line) {
+			p.Failed = true
+		}
+	default:
+		b, err := parse.ParseLine(line)
+		if err != nil {
+			// A benchmark name alone on a line, as printed
+			// before any benchmark log output, is not an error.
+			if strings.HasPrefix(line, "Benchmark") && len(strings.Fields(line)) > 1 {
+				p.Errors++
+				if p.OnError != nil {
+					p.OnError(p.Lines, line, err)
+				}
+			}
+			return Result{}, false
+		}
+		p.Benchmarks++
+		return Result{
+			Benchmark: Benchmark{Benchmark: *b, Extra: ParseExtraMetrics(line), Raw: line},
+			Pkg:       p.Pkg,
+			GOOS:      p.GOOS,
+			GOARCH:    p.GOARCH,
+			CPU:       p.CPU,
+		}, true
+	}
+	return Result{}, false
+}
+
+// isTestOutputLine reports whether line is known output of "go test"
+// other than a benchmark result, such as "PASS" or "ok  pkg  1.234s".
+func isTestOutputLine(line string) bool {
+	switch strings.TrimSpace(line) {
+	case "", "PASS", "FAIL":
+		return true
+	}
+	for _, prefix := range []string{"ok ", "FAIL\t", "?   ", "--- ", "=== ", "exit status "} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFailureLine reports whether line is output of "go test"
+// reporting a failure, such as "FAIL\tpkg\t1.234s" or "--- FAIL: BenchmarkFoo".
+func isTestFailureLine(line string) bool {
+	return strings.TrimSpace(line) == "FAIL" ||
+		strings.HasPrefix(line, "FAIL\t") ||
+		strings.HasPrefix(line, "--- FAIL:")
+}
+
+// Parse parses each line of r, calling f with each benchmark result.
+func (p *Parser) Parse(r io.Reader, f func(Result) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if result, ok := p.ParseLine(scanner.Text()); ok {
+			if err := f(result); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
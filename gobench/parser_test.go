@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_Parser(t *testing.T) {
+	type result struct {
+		name, pkg, goos, goarch, cpu string
+		nsPerOp                      float64
+		extra                        map[string]float64
+	}
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected []result
+		errors   int
+	}{{
+		name:  "no headers",
+		input: "BenchmarkFoo-8\t100\t12.5 ns/op\n",
+		expected: []result{
+			{name: "BenchmarkFoo-8", nsPerOp: 12.5},
+		},
+	}, {
+		name: "multiple packages",
+		input: `goos: linux
+goarch: amd64
+pkg: example.com/foo
+cpu: Intel(R) Xeon(R) CPU @ 2.20GHz
+BenchmarkFoo-8   	 100	      12.5 ns/op
+PASS
+ok  	example.com/foo	1.234s
+goos: linux
+goarch: amd64
+pkg: example.com/bar
+BenchmarkBar-8   	 200	      25 ns/op	  1024 events/sec
+PASS
+ok  	example.com/bar	2.345s
+`,
+		expected: []result{
+			{name: "BenchmarkFoo-8", pkg: "example.com/foo", goos: "linux", goarch: "amd64", cpu: "Intel(R) Xeon(R) CPU @ 2.20GHz", nsPerOp: 12.5},
+			// Headers persist until they are replaced.
+			{name: "BenchmarkBar-8", pkg: "example.com/bar", goos: "linux", goarch: "amd64", cpu: "Intel(R) Xeon(R) CPU @ 2.20GHz", nsPerOp: 25, extra: map[string]float64{"events_sec": 1024}},
+		},
+	}, {
+		name: "headers change between results",
+		input: `goos: linux
+BenchmarkFoo-8	100	12.5 ns/op
+goos: darwin
+goarch: arm64
+BenchmarkFoo-8	100	10 ns/op
+`,
+		expected: []result{
+			{name: "BenchmarkFoo-8", goos: "linux", nsPerOp: 12.5},
+			{name: "BenchmarkFoo-8", goos: "darwin", goarch: "arm64", nsPerOp: 10},
+		},
+	}, {
+		name: "log output and errors",
+		input: `BenchmarkFoo
+    foo_test.go:10: some log output
+BenchmarkFoo-8	many	12.5 ns/op
+BenchmarkFoo-8	100	12.5 ns/op
+`,
+		expected: []result{
+			{name: "BenchmarkFoo-8", nsPerOp: 12.5},
+		},
+		errors: 1,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p Parser
+			var results []result
+			err := p.Parse(strings.NewReader(tc.input), func(r Result) error {
+				assert.NotZero(t, r.Measured&parse.NsPerOp)
+				results = append(results, result{
+					name:    r.Name,
+					pkg:     r.Pkg,
+					goos:    r.GOOS,
+					goarch:  r.GOARCH,
+					cpu:     r.CPU,
+					nsPerOp: r.NsPerOp,
+					extra:   r.Extra,
+				})
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, results)
+			assert.Equal(t, strings.Count(tc.input, "\n"), p.Lines)
+			assert.Equal(t, len(tc.expected), p.Benchmarks)
+			assert.Equal(t, tc.errors, p.Errors)
+		})
+	}
+}
+
+func Test_ParserOnError(t *testing.T) {
+	input := `goos: linux
+pkg: example.com/foo
+BenchmarkFoo
+    foo_test.go:10: some log output
+BenchmarkFoo-8	100	12.5 ns/op
+
+--- BENCH: BenchmarkBar-8
+BenchmarkBar-8	many	12.5 ns/op
+BenchmarkBaz-8	1e
+PASS
+ok  	example.com/foo	1.234s
+FAIL	example.com/bar [build failed]
+?   	example.com/baz	[no test files]
+`
+	type parseError struct {
+		lineNum int
+		line    string
+	}
+	var errs []parseError
+	p := Parser{OnError: func(lineNum int, line string, err error) {
+		assert.Error(t, err)
+		errs = append(errs, parseError{lineNum, line})
+	}}
+	var results int
+	require.NoError(t, p.Parse(strings.NewReader(input), func(Result) error {
+		results++
+		return nil
+	}))
+	assert.Equal(t, 1, results)
+	assert.Equal(t, []parseError{
+		{8, "BenchmarkBar-8\tmany\t12.5 ns/op"},
+		{9, "BenchmarkBaz-8\t1e"},
+	}, errs)
+	assert.Equal(t, 2, p.Errors)
+}
+
+func Test_ParserTestOutput(t *testing.T) {
+	// The complete output of "go test -bench . -benchmem ./..." for
+	// several packages, one of which failed.
+	input := `goos: linux
+goarch: amd64
+pkg: example.com/foo
+cpu: Intel(R) Xeon(R) CPU @ 2.20GHz
+BenchmarkFoo
+BenchmarkFoo-8   	 1000000	      1052 ns/op	     128 B/op	       2 allocs/op
+BenchmarkFoo
+    foo_test.go:10: some log output
+BenchmarkBar/small-8         	 5000000	       251.0 ns/op
+BenchmarkBar/large-8         	   50000	     25312 ns/op
+PASS
+ok  	example.com/foo	4.123s
+?   	example.com/foo/internal	[no test files]
+goos: linux
+goarch: amd64
+pkg: example.com/bar
+BenchmarkBaz-8   	     100	  10524361 ns/op
+--- FAIL: BenchmarkQux-8
+    bar_test.go:42: unexpected result
+FAIL
+exit status 1
+FAIL	example.com/bar	2.001s
+FAIL
+`
+	p := Parser{OnError: func(lineNum int, line string, err error) {
+		t.Errorf("unexpected error at line %d %q: %s", lineNum, line, err)
+	}}
+	var pkgs []string
+	require.NoError(t, p.Parse(strings.NewReader(input), func(r Result) error {
+		pkgs = append(pkgs, r.Pkg)
+		return nil
+	}))
+	assert.Equal(t, []string{"example.com/foo", "example.com/foo", "example.com/foo", "example.com/bar"}, pkgs)
+	assert.Equal(t, 4, p.Benchmarks)
+	assert.Equal(t, 0, p.Errors)
+	assert.True(t, p.Failed)
+
+	// The same output without the failing package.
+	p = Parser{}
+	require.NoError(t, p.Parse(strings.NewReader(input[:strings.Index(input, "goos: linux\ngoarch: amd64\npkg: example.com/bar")]), func(Result) error {
+		return nil
+	}))
+	assert.Equal(t, 3, p.Benchmarks)
+	assert.False(t, p.Failed)
+}
@@ -0,0 +1,223 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+// minIndexTemplateVersion is the first version of Elasticsearch
+// supporting composable index templates.
+var minIndexTemplateVersion = semver.MustParse("7.8.0")
+
+// indexDatePattern matches the Go time layouts, enclosed in braces,
+// which may be included in index names; e.g. "gobench-{2006.01.02}".
+var indexDatePattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// IsIndexPattern reports whether index contains date patterns.
+func IsIndexPattern(index string) bool {
+	return indexDatePattern.MatchString(index)
+}
+
+// ValidateIndexPattern returns an error if index contains unbalanced
+// braces, or if the name to which it resolves, with its date patterns
+// expanded, is not a valid index name; see ValidateIndexName.
+func ValidateIndexPattern(index string) error {
+	if strings.ContainsAny(indexDatePattern.ReplaceAllString(index, ""), "{}") {
+		return errors.Errorf("invalid index %q: unbalanced braces", index)
+	}
+	if err := ValidateIndexName(expandIndexName(index, time.Now().UTC())); err != nil {
+		if IsIndexPattern(index) {
+			return errors.Wrapf(err, "invalid index %q", index)
+		}
+		return err
+	}
+	return nil
+}
+
+// invalidIndexNameChars are the characters which Elasticsearch does not
+// permit in index names.
+const invalidIndexNameChars = `\/*?"<>| ,#`
+
+// ValidateIndexName returns an error if name is not a valid Elasticsearch
+// index name: it must be lowercase, must not contain any of the characters
+// \ / * ? " < > | , # or a space, must not begin with '-', '_' or '+', must
+// not be "." or "..", and must be at most 255 bytes long.
+func ValidateIndexName(name string) error {
+	var reason string
+	switch {
+	case name == "":
+		reason = "must not be empty"
+	case strings.ToLower(name) != name:
+		reason = "must be lowercase"
+	case strings.ContainsAny(name, invalidIndexNameChars):
+		reason = `must not contain any of \/*?"<>| ,#`
+	case strings.IndexAny(name[:1], "-_+") == 0:
+		reason = "must not begin with '-', '_' or '+'"
+	case name == "." || name == "..":
+		reason = `must not be "." or ".."`
+	case len(name) > maxIndexNameBytes:
+		reason = fmt.Sprintf("must be at most %d bytes long", maxIndexNameBytes)
+	default:
+		return nil
+	}
+	return errors.Errorf("invalid index name %q: %s", name, reason)
+}
+
+// expandIndexName returns index with each date pattern replaced by t
+// formatted with the enclosed layout. Index names must be lowercase,
+// so the formatted dates are lowercased.
+func expandIndexName(index string, t time.Time) string {
+	return indexDatePattern.ReplaceAllStringFunc(index, func(match string) string {
+		return strings.ToLower(t.Format(match[1 : len(match)-1]))
+	})
+}
+
+// maxIndexNameBytes is the maximum length of an Elasticsearch index name.
+const maxIndexNameBytes = 255
+
+// unknownPackageIndexSuffix is the suffix of the per-package index into
+// which documents without a package are indexed.
+const unknownPackageIndexSuffix = "unknown"
+
+// packageIndexName returns the name of the index for benchmarks of pkg
+// when cfg.PerPackageIndex is set: index followed by pkg, lowercased and
+// with each run of characters other than letters and digits replaced by a
+// hyphen, e.g. "gobench-github-com-foo-bar" for "github.com/foo/bar". The
+// result is truncated if necessary to a valid index name length.
+func packageIndexName(index, pkg string) string {
+	suffix := indexNameSuffix(pkg)
+	if suffix == "" {
+		suffix = unknownPackageIndexSuffix
+	}
+	return joinIndexName(index, suffix)
+}
+
+// tagIndexName returns the name of the index for benchmarks whose tag
+// named by cfg.IndexFromTag has the given value: index followed by the
+// value, sanitized as by packageIndexName, e.g. "gobench-search" for
+// "search". If the value has no letters or digits, index is returned.
+func tagIndexName(index, value string) string {
+	suffix := indexNameSuffix(value)
+	if suffix == "" {
+		return index
+	}
+	return joinIndexName(index, suffix)
+}
+
+// indexNameSuffix returns s lowercased, with each run of characters other
+// than letters and digits replaced by a hyphen, and without leading or
+// trailing hyphens.
+func indexNameSuffix(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// joinIndexName returns index followed by a hyphen and suffix, truncated
+// if necessary to a valid index name length.
+func joinIndexName(index, suffix string) string {
+	name := index + "-" + suffix
+	if len(name) > maxIndexNameBytes {
+		name = strings.TrimRight(name[:maxIndexNameBytes], "-")
+	}
+	return name
+}
+
+// indexTemplate returns the name and index patterns of the index
+// template used for cfg.Index. Date patterns in the index name are
+// replaced with wildcards, and the template is named after the prefix
+// preceding the first date pattern. If cfg.PerPackageIndex is set, the
+// patterns match the per-package indices.
+func indexTemplate(cfg Config) (name string, patterns []string) {
+	suffix := "*"
+	if cfg.PerPackageIndex {
+		suffix = "-*"
+	}
+	if !IsIndexPattern(cfg.Index) {
+		return cfg.Index, []string{cfg.Index + suffix}
+	}
+	name = strings.TrimRight(cfg.Index[:strings.IndexRune(cfg.Index, '{')], "-_.")
+	if name == "" {
+		name = "gobench"
+	}
+	pattern := indexDatePattern.ReplaceAllString(cfg.Index, "*")
+	if cfg.PerPackageIndex {
+		pattern += suffix
+	}
+	return name, []string{pattern}
+}
+
+// createIndexTemplate creates or updates a composable index template
+// carrying the benchmark field mappings, matching indices whose names
+// begin with cfg.Index. Putting a template is idempotent, so an existing
+// template is simply replaced.
+func createIndexTemplate(ctx context.Context, cfg Config, esVersion *semver.Version) error {
+	if esVersion != nil && esVersion.LT(minIndexTemplateVersion) {
+		return errors.Errorf(
+			"index templates require Elasticsearch %s or later, found %s",
+			minIndexTemplateVersion, esVersion,
+		)
+	}
+	name, patterns := indexTemplate(cfg)
+	template := map[string]interface{}{"mappings": esMappings(false, cfg.MappingOverrides)}
+	if settings := esIndexSettings(cfg); settings != nil {
+		template["settings"] = settings
+	}
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]interface{}{
+		"index_patterns": patterns,
+		"template":       template,
+	}); err != nil {
+		return err
+	}
+
+	templateURL := cfg.URL + "/_index_template/" + url.PathEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, templateURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cfg.do(req)
+	if err != nil {
+		return err
+	}
+	return handleResponse(resp, cfg.logger())
+}
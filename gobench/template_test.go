@@ -0,0 +1,145 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_createIndexTemplate(t *testing.T) {
+	var method, path string
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &body))
+		w.Write([]byte(`{"acknowledged":true}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{URL: srv.URL, Index: "gobench", UseTemplate: true}
+	require.NoError(t, createMapping(context.Background(), cfg, nil))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/_index_template/gobench", path)
+	assert.Equal(t, []interface{}{"gobench*"}, body["index_patterns"])
+
+	template := body["template"].(map[string]interface{})
+	mappings := template["mappings"].(map[string]interface{})
+	assert.Contains(t, mappings, "properties")
+	assert.Contains(t, mappings, "dynamic_templates")
+	assert.Contains(t, mappings["properties"], FieldNSPerOp)
+}
+
+func Test_createIndexTemplateUnsupportedVersion(t *testing.T) {
+	cfg := Config{URL: "http://127.0.0.1:0", Index: "gobench", UseTemplate: true}
+	err := createMapping(context.Background(), cfg, &semver.Version{Major: 7, Minor: 7})
+	assert.EqualError(t, err, "index templates require Elasticsearch 7.8.0 or later, found 7.7.0")
+}
+
+func Test_indexTemplate(t *testing.T) {
+	for index, expected := range map[string]struct {
+		name     string
+		patterns []string
+	}{
+		"gobench":                  {"gobench", []string{"gobench*"}},
+		"gobench-{2006.01.02}":     {"gobench", []string{"gobench-*"}},
+		"gobench-{2006}-{01}-runs": {"gobench", []string{"gobench-*-*-runs"}},
+		"{2006.01.02}":             {"gobench", []string{"*"}},
+	} {
+		name, patterns := indexTemplate(Config{Index: index})
+		assert.Equal(t, expected.name, name, index)
+		assert.Equal(t, expected.patterns, patterns, index)
+	}
+}
+
+func Test_indexTemplatePerPackage(t *testing.T) {
+	for index, expected := range map[string]struct {
+		name     string
+		patterns []string
+	}{
+		"gobench":              {"gobench", []string{"gobench-*"}},
+		"gobench-{2006.01.02}": {"gobench", []string{"gobench-*-*"}},
+	} {
+		name, patterns := indexTemplate(Config{Index: index, PerPackageIndex: true})
+		assert.Equal(t, expected.name, name, index)
+		assert.Equal(t, expected.patterns, patterns, index)
+	}
+}
+
+func Test_packageIndexName(t *testing.T) {
+	for pkg, expected := range map[string]string{
+		"github.com/foo/bar":        "gobench-github-com-foo-bar",
+		"github.com/Foo/Bar_Baz/v2": "gobench-github-com-foo-bar-baz-v2",
+		"example.com/x/_internal/":  "gobench-example-com-x-internal",
+		"":                          "gobench-unknown",
+		"/../":                      "gobench-unknown",
+	} {
+		assert.Equal(t, expected, packageIndexName("gobench", pkg), pkg)
+	}
+
+	name := packageIndexName("gobench", strings.Repeat("a/", 200))
+	assert.Len(t, name, maxIndexNameBytes)
+	assert.True(t, strings.HasSuffix(name, "-a"), name)
+}
+
+func Test_validateIndexPattern(t *testing.T) {
+	assert.NoError(t, ValidateIndexPattern("gobench"))
+	assert.NoError(t, ValidateIndexPattern("gobench-{2006.01.02}"))
+	assert.Error(t, ValidateIndexPattern("gobench-{2006.01.02"))
+	assert.Error(t, ValidateIndexPattern("gobench-}"))
+	assert.EqualError(t, ValidateIndexPattern("Gobench-{2006.01.02}"),
+		`invalid index "Gobench-{2006.01.02}": invalid index name "Gobench-`+time.Now().UTC().Format("2006.01.02")+`": must be lowercase`)
+	assert.EqualError(t, ValidateIndexPattern("gobench-{2006/01}"),
+		`invalid index "gobench-{2006/01}": invalid index name "gobench-`+time.Now().UTC().Format("2006/01")+`": must not contain any of \/*?"<>| ,#`)
+}
+
+func Test_ValidateIndexName(t *testing.T) {
+	for _, name := range []string{"gobench", "gobench-2024.01.02", ".gobench", "go+bench", strings.Repeat("a", 255)} {
+		assert.NoError(t, ValidateIndexName(name), name)
+	}
+	for name, reason := range map[string]string{
+		"":                       "must not be empty",
+		"GoBench":                "must be lowercase",
+		"go bench":               `must not contain any of \/*?"<>| ,#`,
+		"go/bench":               `must not contain any of \/*?"<>| ,#`,
+		"go#bench":               `must not contain any of \/*?"<>| ,#`,
+		`go"bench`:               `must not contain any of \/*?"<>| ,#`,
+		"go,bench":               `must not contain any of \/*?"<>| ,#`,
+		"-gobench":               "must not begin with '-', '_' or '+'",
+		"_gobench":               "must not begin with '-', '_' or '+'",
+		"+gobench":               "must not begin with '-', '_' or '+'",
+		".":                      `must not be "." or ".."`,
+		"..":                     `must not be "." or ".."`,
+		strings.Repeat("a", 256): "must be at most 255 bytes long",
+	} {
+		assert.EqualError(t, ValidateIndexName(name), fmt.Sprintf("invalid index name %q: %s", name, reason))
+	}
+}
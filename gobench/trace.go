@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// traceRequest returns req with an httptrace.ClientTrace which logs the
+// DNS resolution, connection, TLS handshake and time to first response
+// byte of each attempt to send it.
+func traceRequest(req *http.Request, logger *slog.Logger) *http.Request {
+	t := &requestTracer{
+		logger:   logger.With("method", req.Method, "url", req.URL.Redacted()),
+		connects: make(map[string]time.Time),
+	}
+	trace := &httptrace.ClientTrace{
+		GetConn:              t.getConn,
+		DNSStart:             t.dnsStart,
+		DNSDone:              t.dnsDone,
+		ConnectStart:         t.connectStart,
+		ConnectDone:          t.connectDone,
+		TLSHandshakeStart:    t.tlsHandshakeStart,
+		TLSHandshakeDone:     t.tlsHandshakeDone,
+		GotConn:              t.gotConn,
+		GotFirstResponseByte: t.gotFirstResponseByte,
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// requestTracer implements the hooks of an httptrace.ClientTrace. The
+// hooks may be called concurrently, e.g. when dialing several addresses.
+type requestTracer struct {
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// start is the time at which the current attempt began.
+	start    time.Time
+	dns      time.Time
+	connects map[string]time.Time
+	tls      time.Time
+}
+
+func (t *requestTracer) log(event string, args ...interface{}) {
+	t.logger.Info("http trace", append([]interface{}{"event", event}, args...)...)
+}
+
+// since returns the time elapsed since *start, which is read under t.mu.
+func (t *requestTracer) since(start *time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Since(*start)
+}
+
+func (t *requestTracer) getConn(hostPort string) {
+	t.mu.Lock()
+	t.start = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *requestTracer) dnsStart(httptrace.DNSStartInfo) {
+	t.mu.Lock()
+	t.dns = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *requestTracer) dnsDone(info httptrace.DNSDoneInfo) {
+	addrs := make([]string, len(info.Addrs))
+	for i, addr := range info.Addrs {
+		addrs[i] = addr.String()
+	}
+	t.log("dns", "addrs", addrs, "duration", t.since(&t.dns), "error", info.Err)
+}
+
+func (t *requestTracer) connectStart(network, addr string) {
+	t.mu.Lock()
+	t.connects[network+" "+addr] = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *requestTracer) connectDone(network, addr string, err error) {
+	t.mu.Lock()
+	duration := time.Since(t.connects[network+" "+addr])
+	t.mu.Unlock()
+	t.log("connect", "network", network, "addr", addr, "duration", duration, "error", err)
+}
+
+func (t *requestTracer) tlsHandshakeStart() {
+	t.mu.Lock()
+	t.tls = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *requestTracer) tlsHandshakeDone(state tls.ConnectionState, err error) {
+	t.log("tls_handshake",
+		"version", tls.VersionName(state.Version),
+		"resumed", state.DidResume,
+		"duration", t.since(&t.tls),
+		"error", err,
+	)
+}
+
+func (t *requestTracer) gotConn(info httptrace.GotConnInfo) {
+	args := []interface{}{
+		"reused", info.Reused,
+		"was_idle", info.WasIdle,
+		"duration", t.since(&t.start),
+	}
+	if info.WasIdle {
+		args = append(args, "idle_time", info.IdleTime)
+	}
+	if info.Conn != nil {
+		args = append(args, "remote_addr", info.Conn.RemoteAddr().String())
+	}
+	t.log("got_conn", args...)
+}
+
+func (t *requestTracer) gotFirstResponseByte() {
+	t.log("first_byte", "duration", t.since(&t.start))
+}
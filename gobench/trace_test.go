@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// traceRecorder is a slog.Handler which records the attributes of each
+// "http trace" record in events, which is shared by derived handlers.
+type traceRecorder struct {
+	events *traceEvents
+	attrs  []slog.Attr
+}
+
+type traceEvents struct {
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+func newTraceRecorder() traceRecorder {
+	return traceRecorder{events: &traceEvents{}}
+}
+
+func (r traceRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r traceRecorder) Handle(_ context.Context, record slog.Record) error {
+	if record.Message != "http trace" {
+		return nil
+	}
+	event := make(map[string]interface{})
+	for _, attr := range r.attrs {
+		event[attr.Key] = attr.Value.Any()
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		event[attr.Key] = attr.Value.Any()
+		return true
+	})
+	r.events.mu.Lock()
+	defer r.events.mu.Unlock()
+	r.events.events = append(r.events.events, event)
+	return nil
+}
+
+func (r traceRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	r.attrs = append(append([]slog.Attr(nil), r.attrs...), attrs...)
+	return r
+}
+
+func (r traceRecorder) WithGroup(string) slog.Handler { return r }
+
+// take returns the events recorded so far, by name, and resets them.
+func (r traceRecorder) take() map[string]map[string]interface{} {
+	r.events.mu.Lock()
+	defer r.events.mu.Unlock()
+	byName := make(map[string]map[string]interface{})
+	for _, event := range r.events.events {
+		byName[event["event"].(string)] = event
+	}
+	r.events.events = nil
+	return byName
+}
+
+func Test_ConfigTrace(t *testing.T) {
+	srv, _ := newTLSServer(t)
+	recorder := newTraceRecorder()
+	cfg := Config{URL: srv.URL, Client: srv.Client(), Logger: slog.New(recorder), Trace: true}
+
+	get := func() {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := cfg.do(req)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	get()
+	events := recorder.take()
+	for _, name := range []string{"connect", "tls_handshake", "got_conn", "first_byte"} {
+		require.Contains(t, events, name)
+		assert.Equal(t, http.MethodGet, events[name]["method"], name)
+		assert.Equal(t, srv.URL, events[name]["url"], name)
+	}
+	assert.Equal(t, "TLS 1.3", events["tls_handshake"]["version"])
+	assert.Nil(t, events["tls_handshake"]["error"])
+	assert.Equal(t, false, events["got_conn"]["reused"])
+
+	// The second request reuses the idle connection, so there is no
+	// connection or handshake to trace.
+	get()
+	events = recorder.take()
+	assert.NotContains(t, events, "connect")
+	assert.NotContains(t, events, "tls_handshake")
+	require.Contains(t, events, "got_conn")
+	assert.Equal(t, true, events["got_conn"]["reused"])
+	assert.Equal(t, true, events["got_conn"]["was_idle"])
+	assert.Contains(t, events, "first_byte")
+
+	cfg.Trace = false
+	get()
+	assert.Empty(t, recorder.take())
+}
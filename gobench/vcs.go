@@ -0,0 +1,313 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package gobench
+
+import (
+	"bytes"
+	"go/build"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// execCommand runs the named command in dir, returning its standard
+// output and standard error. It is a variable so tests can stub out
+// command execution.
+var execCommand = func(dir, name string, args ...string) (stdout, stderr []byte, err error) {
+	var outBuf, errBuf bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	err = cmd.Run()
+	return outBuf.Bytes(), errBuf.Bytes(), err
+}
+
+// runCommand runs the named command in dir, returning its combined
+// output.
+func runCommand(dir, name string, args ...string) ([]byte, error) {
+	stdout, stderr, err := execCommand(dir, name, args...)
+	return append(stdout, stderr...), err
+}
+
+// runCommandOutput runs the named command in dir, returning only its
+// standard output, for commands whose warnings on standard error must
+// not be mistaken for output.
+func runCommandOutput(dir, name string, args ...string) ([]byte, error) {
+	stdout, _, err := execCommand(dir, name, args...)
+	return stdout, err
+}
+
+// ciBranchEnvVars are environment variables set by CI systems to the
+// branch being built, in order of preference. These are used when the
+// repository has a detached HEAD, as is common in CI.
+var ciBranchEnvVars = []string{
+	"GITHUB_HEAD_REF",    // GitHub Actions, pull requests
+	"GITHUB_REF_NAME",    // GitHub Actions
+	"CI_COMMIT_REF_NAME", // GitLab CI
+	"BUILDKITE_BRANCH",   // Buildkite
+	"BRANCH_NAME",        // Jenkins multibranch pipelines
+	"GIT_BRANCH",         // Jenkins git plugin
+}
+
+// vcsMetadataDirs holds the metadata directory names of supported version
+// control systems and their commands, in order of preference when a
+// directory contains more than one.
+var vcsMetadataDirs = []struct {
+	dir, cmd string
+}{
+	{".git", "git"},
+	{".hg", "hg"},
+}
+
+// vcsCacheMu protects vcsCache and pkgDirs, which cache the fields added
+// by addVCSDir for each directory and the directories of the packages
+// found by addVCS, so that the commands are run once per directory rather
+// than for every document.
+var (
+	vcsCacheMu sync.Mutex
+	vcsCache   = make(map[string]map[string]interface{})
+	pkgDirs    = make(map[string]string)
+)
+
+// resetVCSCache clears the caches used by addVCS and addVCSDir.
+func resetVCSCache() {
+	vcsCacheMu.Lock()
+	defer vcsCacheMu.Unlock()
+	vcsCache = make(map[string]map[string]interface{})
+	pkgDirs = make(map[string]string)
+}
+
+func addVCS(pkgpath string, doc map[string]interface{}) {
+	vcsCacheMu.Lock()
+	dir, ok := pkgDirs[pkgpath]
+	if !ok {
+		if pkg, err := build.Import(pkgpath, "", build.FindOnly); err == nil {
+			dir = pkg.Dir
+		}
+		pkgDirs[pkgpath] = dir
+	}
+	vcsCacheMu.Unlock()
+	if dir != "" {
+		addVCSDir(dir, doc)
+	}
+}
+
+// addVCSDir adds details of the most recent commit in the repository
+// containing dir to doc. The details are read once for each dir, and
+// copied into each document.
+func addVCSDir(dir string, doc map[string]interface{}) {
+	vcsCacheMu.Lock()
+	fields, ok := vcsCache[dir]
+	if !ok {
+		fields = make(map[string]interface{})
+		readVCSDir(dir, fields)
+		vcsCache[dir] = fields
+	}
+	vcsCacheMu.Unlock()
+	for field, value := range fields {
+		doc[field] = copyValue(value)
+	}
+}
+
+// copyValue returns v, with any nested maps copied so that documents do
+// not share them.
+func copyValue(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	c := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		c[key] = copyValue(value)
+	}
+	return c
+}
+
+// readVCSDir adds details of the most recent commit in the repository
+// containing dir to doc.
+func readVCSDir(dir string, doc map[string]interface{}) {
+	switch vcsFromDir(dir) {
+	case "git":
+		output, err := runCommand(dir, "git", "log", "-1", "--format="+gitLogFormat)
+		if err != nil {
+			return
+		}
+		if gitFields := parseGitLog(string(output)); gitFields != nil {
+			if branch := gitBranch(dir); branch != "" {
+				gitFields[FieldGitBranch] = branch
+			}
+			// git status warns on stderr, e.g. of unreadable directories,
+			// which must not mark the tree as dirty.
+			if output, err := runCommandOutput(dir, "git", "status", "--porcelain"); err == nil {
+				gitFields[FieldGitDirty] = len(bytes.TrimSpace(output)) > 0
+			}
+			if output, err := runCommand(dir, "git", "config", "--get", "remote.origin.url"); err == nil {
+				if remote := normalizeGitRemote(strings.TrimSpace(string(output))); remote != "" {
+					gitFields[FieldGitRemote] = remote
+				}
+			}
+			doc[FieldGit] = gitFields
+		}
+	case "hg":
+		// hgdate is formatted as "<unix seconds> <timezone offset>".
+		output, err := runCommand(dir, "hg", "log", "-l", "1", "--template", "{node} {date|hgdate} {desc|firstline}")
+		if err != nil {
+			return
+		}
+		// The subject is missing if the description is empty.
+		fields := strings.SplitN(strings.TrimSpace(string(output)), " ", 4)
+		if len(fields) >= 3 {
+			var subject string
+			if len(fields) == 4 {
+				subject = fields[3]
+			}
+			doc[FieldHg] = vcsFields(fields[0], fields[1], subject)
+		}
+	}
+}
+
+// gitBranch returns the name of the branch checked out in the git
+// repository containing dir. If HEAD is detached, the branch is taken
+// from the first of ciBranchEnvVars which is set.
+func gitBranch(dir string) string {
+	output, err := runCommand(dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return ""
+	}
+	if branch := strings.TrimSpace(string(output)); branch != "HEAD" {
+		return branch
+	}
+	for _, key := range ciBranchEnvVars {
+		if branch := os.Getenv(key); branch != "" {
+			return branch
+		}
+	}
+	return ""
+}
+
+// scpLikeGitRemote matches the scp-like syntax for git remotes accessed
+// over SSH, e.g. "git@github.com:org/repo.git".
+var scpLikeGitRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// normalizeGitRemote returns the https form of a git remote URL, without
+// credentials, SSH port or ".git" suffix, so that clones over SSH and HTTPS
+// have the same remote; e.g. "https://github.com/org/repo" for both
+// "git@github.com:org/repo.git" and "https://token@github.com/org/repo".
+// Remotes which are local paths, or cannot be parsed, are omitted by
+// returning "".
+func normalizeGitRemote(remote string) string {
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil || u.Host == "" {
+			return ""
+		}
+		switch u.Scheme {
+		case "http":
+			// Keep plain HTTP, since the server may not support HTTPS.
+			u.User = nil
+			u.Path = strings.TrimSuffix(u.Path, ".git")
+			return u.String()
+		case "https":
+			host, path = u.Host, u.Path
+		case "ssh", "git", "git+ssh", "ssh+git":
+			// The port is that of the SSH or git daemon, not HTTPS.
+			host, path = u.Hostname(), u.Path
+		default:
+			return ""
+		}
+	} else if m := scpLikeGitRemote.FindStringSubmatch(remote); m != nil {
+		host, path = m[1], m[2]
+	} else {
+		return ""
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if path == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: "https", Host: host, Path: "/" + path}).String()
+}
+
+// gitLogFormat is the "git log" format used for obtaining commit details,
+// parsed by parseGitLog. Fields are separated by NUL, since author names
+// and subjects may contain spaces.
+const gitLogFormat = "%H%x00%ct%x00%s%x00%an%x00%ae%x00%at"
+
+// parseGitLog parses the output of "git log" with gitLogFormat, returning
+// nil if it is malformed.
+func parseGitLog(output string) map[string]interface{} {
+	fields := strings.Split(strings.TrimRight(output, "\n"), "\x00")
+	if len(fields) != 6 {
+		return nil
+	}
+	gitFields := vcsFields(fields[0], fields[1], fields[2])
+	author := map[string]interface{}{
+		FieldGitAuthorName:  fields[3],
+		FieldGitAuthorEmail: fields[4],
+	}
+	if unixSec, err := strconv.ParseInt(fields[5], 10, 64); err == nil {
+		author[FieldGitAuthorDate] = time.Unix(unixSec, 0).UTC()
+	}
+	gitFields[FieldGitAuthor] = author
+	return gitFields
+}
+
+// vcsFromDir returns the command for the version control system used by
+// the repository containing dir, or the empty string if there is none.
+//
+// This is used in place of vcs.FromDir, which requires dir to be within a
+// GOPATH source root and so does not work with modules.
+func vcsFromDir(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		for _, vcs := range vcsMetadataDirs {
+			if _, err := os.Stat(filepath.Join(dir, vcs.dir)); err == nil {
+				return vcs.cmd
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// vcsFields returns the fields describing a commit, given its ID,
+// committer date in Unix seconds, and subject.
+func vcsFields(commit, unixSecString, subject string) map[string]interface{} {
+	fields := map[string]interface{}{
+		FieldGitCommit:  commit,
+		FieldGitSubject: subject,
+	}
+	unixSec, err := strconv.ParseInt(unixSecString, 10, 64)
+	if err == nil {
+		committerDate := time.Unix(unixSec, 0).UTC()
+		fields[FieldGitCommitter] = map[string]interface{}{
+			FieldGitCommitterDate: committerDate,
+		}
+	}
+	return fields
+}
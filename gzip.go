@@ -0,0 +1,50 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// gzipMagic is the prefix of a gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gunzipInput returns a reader of the decompressed contents of r if r
+// begins with gzipMagic, or if force is true, and otherwise a reader of
+// r's contents unchanged.
+func gunzipInput(r io.Reader, force bool) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if !force {
+		// Peek returns fewer bytes, and an error, for short input,
+		// which is then not gzipped.
+		prefix, _ := br.Peek(len(gzipMagic))
+		if !bytes.Equal(prefix, gzipMagic) {
+			return br, nil
+		}
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading gzipped input")
+	}
+	return zr, nil
+}
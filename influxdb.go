@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+const influxDBMeasurement = "gobench"
+
+var influxDBEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxDBCoreTags are the tags identifying each benchmark, which
+// user-defined tags must not replace.
+var influxDBCoreTags = []string{
+	gobench.FieldPkg,
+	gobench.FieldName,
+	gobench.FieldGOOS,
+	gobench.FieldGOARCH,
+	gobench.FieldCPU,
+}
+
+// influxDBFormat encodes benchmark results in InfluxDB line protocol.
+// Each benchmark is written as a point in the "gobench" measurement,
+// with the package, name, goos, goarch, cpu and user-defined tags as tags,
+// and the benchmark metrics as fields.
+type influxDBFormat struct {
+	w io.Writer
+}
+
+func (f influxDBFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	allTags := make(map[string]string, len(tags)+len(influxDBCoreTags))
+	for key, value := range tags {
+		allTags[key] = value
+	}
+	// The core tags take precedence over any user-defined tags with the
+	// same keys, which are rejected by validateFormatTags.
+	allTags[gobench.FieldPkg] = pkg
+	allTags[gobench.FieldName] = b.Name
+	allTags[gobench.FieldGOOS] = goos
+	allTags[gobench.FieldGOARCH] = goarch
+	allTags[gobench.FieldCPU] = cpu
+	tagKeys := make([]string, 0, len(allTags))
+	for key, value := range allTags {
+		// Tags with empty values are not permitted.
+		if value != "" {
+			tagKeys = append(tagKeys, key)
+		}
+	}
+	sort.Strings(tagKeys)
+
+	var buf bytes.Buffer
+	buf.WriteString(influxDBMeasurement)
+	for _, key := range tagKeys {
+		buf.WriteByte(',')
+		buf.WriteString(influxDBEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(influxDBEscaper.Replace(allTags[key]))
+	}
+
+	sep := byte(' ')
+	writeField := func(key, value string) {
+		buf.WriteByte(sep)
+		buf.WriteString(influxDBEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		sep = ','
+	}
+	writeField(gobench.FieldIterations, strconv.Itoa(b.N)+"i")
+	if b.Measured&parse.NsPerOp != 0 {
+		writeField(gobench.FieldNSPerOp, formatInfluxDBFloat(b.NsPerOp))
+	}
+	if b.Measured&parse.MBPerS != 0 {
+		writeField(gobench.FieldMBPerS, formatInfluxDBFloat(b.MBPerS))
+	}
+	if b.Measured&parse.AllocedBytesPerOp != 0 {
+		writeField(gobench.FieldAllocedBytesPerOp, strconv.FormatUint(b.AllocedBytesPerOp, 10)+"i")
+	}
+	if b.Measured&parse.AllocsPerOp != 0 {
+		writeField(gobench.FieldAllocsPerOp, strconv.FormatUint(b.AllocsPerOp, 10)+"i")
+	}
+	extraKeys := make([]string, 0, len(b.Extra))
+	for key := range b.Extra {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		writeField(key, formatInfluxDBFloat(b.Extra[key]))
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	buf.WriteByte('\n')
+	_, err := f.w.Write(buf.Bytes())
+	return err
+}
+
+func (influxDBFormat) flush() error {
+	return nil
+}
+
+func formatInfluxDBFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
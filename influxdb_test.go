@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_influxDBFormat(t *testing.T) {
+	line := "BenchmarkAgentGo-16    \t    1006\t 327431070 ns/op\t       320.7 errors/sec\t     15988 events/sec\t  973598 B/op\t    1922 allocs/op"
+	b, err := parse.ParseLine(line)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	f := influxDBFormat{w: &buf}
+	err = f.encode(
+		gobench.Benchmark{Benchmark: *b, Extra: gobench.ParseExtraMetrics(line)},
+		"github.com/elastic/apm-server", "linux", "amd64", "Intel(R) Xeon(R) CPU @ 2.20GHz",
+		map[string]string{"branch": "main", "run id": "a,b=c"},
+		time.Unix(1700000000, 123),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "gobench,"+
+		"branch=main,cpu=Intel(R)\\ Xeon(R)\\ CPU\\ @\\ 2.20GHz,goarch=amd64,goos=linux,name=BenchmarkAgentGo-16,pkg=github.com/elastic/apm-server,run\\ id=a\\,b\\=c "+
+		"iterations=1006i,ns_per_op=327431070,alloced_bytes_per_op=973598i,allocs_per_op=1922i,errors_sec=320.7,events_sec=15988 "+
+		"1700000000000000123\n", buf.String())
+}
+
+func Test_influxDBFormatEmptyTags(t *testing.T) {
+	var buf bytes.Buffer
+	f := influxDBFormat{w: &buf}
+	b := parse.Benchmark{Name: "BenchmarkFoo", N: 10, NsPerOp: 1.5, Measured: parse.NsPerOp}
+	err := f.encode(gobench.Benchmark{Benchmark: b}, "", "", "", "", nil, time.Unix(1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "gobench,name=BenchmarkFoo iterations=10i,ns_per_op=1.5 1000000000\n", buf.String())
+}
+
+func Test_influxDBFormatCoreTags(t *testing.T) {
+	var buf bytes.Buffer
+	f := influxDBFormat{w: &buf}
+	b := parse.Benchmark{Name: "BenchmarkFoo", N: 10, NsPerOp: 1.5, Measured: parse.NsPerOp}
+	err := f.encode(gobench.Benchmark{Benchmark: b}, "example.com/pkg", "", "", "", map[string]string{"name": "x", "pkg": "y"}, time.Unix(1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, "gobench,name=BenchmarkFoo,pkg=example.com/pkg iterations=10i,ns_per_op=1.5 1000000000\n", buf.String())
+
+	_, err = testReadInputConfig(t, "-format", formatInfluxDB, "-tag", "name=x")
+	assert.EqualError(t, err, `invalid tag key "name": reserved by -format influxdb`)
+	_, err = testReadInputConfig(t, "-format", formatInfluxDB, "-tag", "branch=main")
+	assert.NoError(t, err)
+}
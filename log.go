@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"io"
+	"log/slog"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logFormats lists the supported values of the -log-format flag.
+var logFormats = []string{logFormatText, logFormatJSON}
+
+func isLogFormat(format string) bool {
+	for _, f := range logFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// newLogger returns a logger which writes records of at least level to w,
+// in the named format.
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceErrorAttr}
+	if format == logFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// replaceErrorAttr replaces error values with their messages. Otherwise
+// the text handler formats errors with %+v, which includes the stack
+// traces of errors created with github.com/pkg/errors.
+func replaceErrorAttr(_ []string, a slog.Attr) slog.Attr {
+	if err, ok := a.Value.Any().(error); ok {
+		a.Value = slog.StringValue(err.Error())
+	}
+	return a
+}
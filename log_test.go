@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs sets the default logger to one writing JSON records of at
+// least level to the returned buffer, until the test ends.
+func captureLogs(t *testing.T, level slog.Level) *bytes.Buffer {
+	var buf bytes.Buffer
+	orig := slog.Default()
+	slog.SetDefault(newLogger(&buf, logFormatJSON, level))
+	t.Cleanup(func() { slog.SetDefault(orig) })
+	return &buf
+}
+
+// decodeLogs decodes the JSON log records in buf.
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]interface{}
+		require.NoError(t, dec.Decode(&record))
+		delete(record, "time")
+		records = append(records, record)
+	}
+	return records
+}
+
+func Test_runLogsJSON(t *testing.T) {
+	buf := captureLogs(t, slog.LevelDebug)
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}}
+	require.NoError(t, run(context.Background(), cfg, strings.NewReader(summaryInput), io.Discard))
+
+	assert.Equal(t, []map[string]interface{}{{
+		"level": "DEBUG",
+		"msg":   "error parsing benchmark result",
+		"input": "stdin",
+		"line":  float64(5),
+		"text":  "BenchmarkBar-8   \tmany\t10 ns/op",
+		"error": `strconv.Atoi: parsing "many": invalid syntax`,
+	}, {
+		"level": "INFO",
+		"msg":   "run complete",
+		"summary": map[string]interface{}{
+			"lines":        float64(7),
+			"benchmarks":   float64(2),
+			"parse_errors": float64(1),
+			"written":      float64(2),
+		},
+	}}, decodeLogs(t, buf))
+}
+
+func Test_runLogsLevel(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}}
+	require.NoError(t, run(context.Background(), cfg, strings.NewReader(summaryInput), io.Discard))
+
+	records := decodeLogs(t, buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "run complete", records[0]["msg"])
+}
+
+func Test_runLogsTestFailed(t *testing.T) {
+	buf := captureLogs(t, slog.LevelInfo)
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}}
+	input := summaryInput + "--- FAIL: BenchmarkQux-8\nFAIL\texample.com/foo\t1.234s\n"
+	require.NoError(t, run(context.Background(), cfg, strings.NewReader(input), io.Discard))
+
+	records := decodeLogs(t, buf)
+	require.Len(t, records, 2)
+	assert.Equal(t, "WARN", records[0]["level"])
+	assert.Equal(t, "benchmark output reported a failure; results may be incomplete", records[0]["msg"])
+	assert.Equal(t, "run complete", records[1]["msg"])
+	assert.Equal(t, true, records[1]["summary"].(map[string]interface{})["test_failed"])
+}
+
+func Test_newLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, logFormatText, slog.LevelWarn)
+	logger.Info("ignored")
+	logger.Warn("retrying", "attempt", 2)
+	assert.Contains(t, buf.String(), "level=WARN msg=retrying attempt=2\n")
+	assert.NotContains(t, buf.String(), "ignored")
+}
+
+func Test_newLoggerErrors(t *testing.T) {
+	for _, format := range logFormats {
+		var buf bytes.Buffer
+		logger := newLogger(&buf, format, slog.LevelInfo)
+		logger.Error("failed", "error", errors.Wrap(errors.New("boom"), "error indexing"))
+		assert.Contains(t, buf.String(), "error indexing: boom", format)
+		assert.NotContains(t, buf.String(), ".go:", format)
+	}
+}
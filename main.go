@@ -19,483 +19,451 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/rand"
 	"flag"
 	"fmt"
-	"go/build"
 	"io"
-	"log"
-	"net/http"
-	"net/url"
+	"log/slog"
 	"os"
-	"os/exec"
-	"runtime"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/blang/semver"
-	"github.com/kr/pretty"
+	"github.com/elastic/gobench/gobench"
 	"github.com/pkg/errors"
 	"golang.org/x/tools/benchmark/parse"
-	"golang.org/x/tools/go/vcs"
 )
 
-var (
-	tagsFlag = flag.String(
-		"tag", "",
-		"comma-separated list of key=value pairs to add to each document",
-	)
-
-	verboseFlag = flag.Bool("v", false, "Be verbose")
-)
-
-type esError struct {
-	Type   string `json:"type"`
-	Reason string `json:"reason"`
-}
-
-func (e *esError) Error() string {
-	return e.Reason
-}
+// verboseFlag is set by the -v flag.
+var verboseFlag = new(bool)
 
+// Exit codes returned by gobenchMain.
 const (
-	exceptionResourceAlreadyExists = "resource_already_exists_exception"
+	exitOK = 0
+	// exitFailure is returned for errors other than those below,
+	// including when no documents could be indexed.
+	exitFailure = 1
+	// exitUsage is returned for invalid flags or configuration.
+	exitUsage = 2
+	// exitPartialFailure is returned when some, but not all, documents
+	// failed to be indexed.
+	exitPartialFailure = 3
 )
 
-type elasticsearchConfig struct {
-	host  string
-	user  string
-	pass  string
-	index string
-}
-
-type benchmark struct {
-	parse.Benchmark
-	extra map[string]float64
+func main() {
+	os.Exit(gobenchMain(flag.CommandLine, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
 
-type fieldProperties map[string]interface{}
-
-const (
-	fieldExecutedAt        = "executed_at"
-	fieldName              = "name"
-	fieldIterations        = "iterations"
-	fieldPkg               = "pkg"
-	fieldHostname          = "hostname"
-	fieldGoVersion         = "go_version"
-	fieldOSVersion         = "os_version"
-	fieldGOOS              = "goos"
-	fieldGOARCH            = "goarch"
-	fieldNSPerOp           = "ns_per_op"
-	fieldMBPerS            = "mb_per_s"
-	fieldAllocedBytesPerOp = "alloced_bytes_per_op"
-	fieldAllocsPerOp       = "allocs_per_op"
-
-	fieldGit              = "git"
-	fieldGitCommit        = "commit"
-	fieldGitSubject       = "subject"
-	fieldGitCommitter     = "committer"
-	fieldGitCommitterDate = "date"
-
-	fieldExtraMetrics = "extra_metrics"
-)
-
-var (
-	esFieldProperties = map[string]fieldProperties{
-		fieldExecutedAt:        {"type": "date"},
-		fieldName:              {"type": "keyword"},
-		fieldIterations:        {"type": "long"},
-		fieldPkg:               {"type": "keyword"},
-		fieldHostname:          {"type": "keyword"},
-		fieldGoVersion:         {"type": "keyword"},
-		fieldOSVersion:         {"type": "keyword"},
-		fieldGOOS:              {"type": "keyword"},
-		fieldGOARCH:            {"type": "keyword"},
-		fieldNSPerOp:           {"type": "double"},
-		fieldMBPerS:            {"type": "double"},
-		fieldAllocedBytesPerOp: {"type": "long"},
-		fieldAllocsPerOp:       {"type": "long"},
-		fieldGit: {
-			"properties": map[string]fieldProperties{
-				fieldGitCommit:  {"type": "text"},
-				fieldGitSubject: {"type": "text"},
-				fieldGitCommitter: {
-					"properties": map[string]fieldProperties{
-						fieldGitCommitterDate: {"type": "date"},
-					},
-				},
-			},
-		},
-	}
-	esExtraMetricsDynamicTemplate = map[string]interface{}{
-		fieldExtraMetrics: map[string]interface{}{
-			"path_match": "extra_metrics.*",
-			"mapping": map[string]string{
-				"type": "float",
-			},
-		},
+// gobenchMain reads the configuration from args, sets the default logger to
+// write to stderr, and runs gobench, returning the process exit code. If
+// args begins with the name of a command, its flags are defined on a new
+// flag set rather than fs.
+func gobenchMain(fs *flag.FlagSet, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cmd, args := lookupCommand(args)
+	if cmd != nil {
+		fs = cmd.newFlagSet(stderr)
+	} else {
+		cmd = indexCommand
 	}
-)
-
-func main() {
-	var esConfig elasticsearchConfig
-	flag.StringVar(&esConfig.host,
-		"es", "",
-		`Elasticsearch URL into which the benchmark data should be indexed, e.g. http://localhost:9200`,
-	)
-	flag.StringVar(&esConfig.index,
-		"index", "gobench",
-		"Elasticsearch index into which the benchmarks should be stored.",
-	)
-	flag.StringVar(&esConfig.user, "es-username", "",
-		"Elasticsearch username used for authentication.",
-	)
-	flag.StringVar(&esConfig.pass, "es-password", "",
-		"Elasticsearch password used for authentication.",
-	)
-	flag.Parse()
-
-	tags := make(map[string]string)
-	for _, field := range strings.Split(*tagsFlag, ",") {
-		field = strings.TrimSpace(field)
-		if field == "" {
-			continue
-		}
-		i := strings.IndexRune(field, '=')
-		if i == -1 {
-			fmt.Fprintf(
-				os.Stderr,
-				"invalid key-value pair %q in -tags: missing '='\n",
-				field,
-			)
-			os.Exit(2)
-		}
-		key, value := field[:i], field[i+1:]
-		tags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	cfg, err := readCommandConfig(fs, args, cmd)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if cfg.printVersion {
+		printVersion(stdout)
+		return exitOK
+	}
+	if err := promptPassword(&cfg, stdin, stderr); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitFailure
+	}
+	slog.SetDefault(newLogger(stderr, cfg.logFormat, cfg.logLevel))
+	// The compare command writes nothing else to stdout.
+	cfg.reportOutput = stderr
+	if cfg.discard {
+		cfg.reportOutput = stdout
+	}
+
+	// Interrupting gobench cancels any in-flight requests, so that a
+	// summary of the failed bulk requests is reported before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	err = run(ctx, cfg, stdin, stdout)
+	if err != nil {
+		slog.Error("gobench failed", "error", err)
 	}
+	return exitCode(err)
+}
 
-	var output io.Writer
-	var buf bytes.Buffer
-	var esURL *url.URL
-	if esConfig.host != "" {
-		url, err := url.Parse(esConfig.host)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "invalid Elasticsearch URL %q: %s\n", esConfig.host, err)
-			os.Exit(2)
-		}
-		esURL = url
-		output = &buf
-		if *verboseFlag {
-			output = io.MultiWriter(output, os.Stdout)
-		}
-	} else {
-		output = os.Stdout
-	}
-	encoder := json.NewEncoder(output)
+// partialFailureError is returned by run when some, but not all, documents
+// failed to be indexed.
+type partialFailureError struct {
+	err error
+}
 
-	if esURL != nil {
-		if err := createMapping(esConfig); err != nil {
-			log.Fatalf("error creating/updating mapping: %s", err)
-		}
-	}
+func (e *partialFailureError) Error() string { return e.err.Error() }
 
-	var pkg, goos, goarch string
-	timestamp := time.Now().UTC()
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		switch {
-		case strings.HasPrefix(line, "pkg:"):
-			pkg = strings.TrimSpace(line[len("pkg:"):])
-		case strings.HasPrefix(line, "goos:"):
-			goos = strings.TrimSpace(line[len("goos:"):])
-		case strings.HasPrefix(line, "goarch:"):
-			goarch = strings.TrimSpace(line[len("goarch:"):])
-		default:
-			if b, err := parse.ParseLine(line); err == nil {
-				result := benchmark{Benchmark: *b}
-				result.extra = parseExtraMetrics(line)
-				encodeIndexOp(
-					encoder, result,
-					pkg, goos, goarch,
-					tags, timestamp,
-					esConfig,
-				)
-			}
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-	}
-	if esURL == nil {
-		// Encoded to stdout.
-		return
-	}
+func (e *partialFailureError) Unwrap() error { return e.err }
 
-	bulkURL := *esURL
-	bulkURL.Path += "/_bulk"
-	req, err := http.NewRequest(http.MethodPost, bulkURL.String(), &buf)
-	if esConfig.user != "" && esConfig.pass != "" {
-		req.SetBasicAuth(esConfig.user, esConfig.pass)
-	}
-	req.Header.Set("Content-Type", "application/x-ndjson")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Fatalf("error executing bulk updates: %s", err)
-	}
-	if err := handleResponse(resp); err != nil {
-		log.Fatalf("error executing bulk updates: %s", err)
+// exitCode returns the process exit code for the error returned by run.
+func exitCode(err error) int {
+	var partial *partialFailureError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &partial):
+		return exitPartialFailure
+	default:
+		return exitFailure
 	}
 }
 
-func createMapping(cfg elasticsearchConfig) error {
-	// Versions of Elasticsearch prior to 7.0.0 require type names.
-	esVersion, err := getEsVersion(cfg.host, cfg.user, cfg.pass)
-	if err != nil {
-		return err
-	}
-	includeTypeName := esVersion.LT(semver.MustParse("7.0.0"))
-
-	var body bytes.Buffer
-	properties := map[string]interface{}{
-		"properties":        esFieldProperties,
-		"dynamic_templates": []interface{}{esExtraMetricsDynamicTemplate},
-	}
-	if includeTypeName {
-		properties = map[string]interface{}{"_doc": properties}
-	}
-	if err := json.NewEncoder(&body).Encode(map[string]interface{}{"mappings": properties}); err != nil {
-		return err
+// run reads benchmark output from the input files or stdin, and either indexes the results
+// into Elasticsearch or writes them as bulk actions to the output file or
+// stdout. If a baseline is configured, run returns an error after doing so
+// if any benchmarks regressed.
+func run(ctx context.Context, cfg inputConfig, stdin io.Reader, stdout io.Writer) error {
+	var check *baselineCheck
+	if cfg.baseline != "" {
+		var err error
+		if check, err = loadBaseline(cfg.baseline, cfg.threshold); err != nil {
+			return err
+		}
 	}
-
-	mappingURL := cfg.host + "/" + cfg.index
-	req, err := http.NewRequest(http.MethodPut, mappingURL, &body)
+	runID, err := newRunID()
 	if err != nil {
 		return err
 	}
-	if cfg.user != "" && cfg.pass != "" {
-		req.SetBasicAuth(cfg.user, cfg.pass)
+	cfg.docOptions.Fields = documentFields(cfg)
+	cfg.docOptions.Fields[gobench.FieldRunID] = runID
+	var sum summary
+	err = output(ctx, cfg, stdin, stdout, check, &sum)
+	if sum.testFailed {
+		// Results are still indexed, but may be missing benchmarks
+		// which failed or whose packages did not build.
+		slog.Warn("benchmark output reported a failure; results may be incomplete")
 	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	slog.Info("run complete", "summary", &sum)
 	if err != nil {
+		if sum.es && sum.indexed > 0 && sum.failed > 0 {
+			return &partialFailureError{err: err}
+		}
 		return err
 	}
-	if err := handleResponse(resp); err != nil {
-		esErr, ok := err.(*esError)
-		if ok && esErr.Type == exceptionResourceAlreadyExists {
-			if *verboseFlag {
-				log.Printf("index %q already exists", cfg.index)
-			}
-			return nil
+	if cfg.report == reportBenchstat {
+		if err := check.writeBenchstat(cfg.reportOutput); err != nil {
+			return err
 		}
-		return err
 	}
-	return nil
+	if cfg.failOnEmpty {
+		if err := sum.emptyErr(); err != nil {
+			return err
+		}
+	}
+	return check.err()
 }
 
-func getEsVersion(host, user, pass string) (*semver.Version, error) {
-	req, err := http.NewRequest("GET", host, nil)
-	if err != nil {
-		return nil, err
+// output implements run, recording each benchmark with check if non-nil
+// and counting the lines and documents processed in sum.
+func output(
+	ctx context.Context,
+	cfg inputConfig,
+	stdin io.Reader,
+	stdout io.Writer,
+	check *baselineCheck,
+	sum *summary,
+) error {
+	switch {
+	case cfg.discard:
+		return encodeBenchmarks(cfg, stdin, check.wrap(discardFormat{}), sum)
+	case cfg.outputFile != "":
+		f, err := os.Create(cfg.outputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		out, err := newOutputFormat(cfg.format, w, cfg.es, nil, cfg.docOptions)
+		if err != nil {
+			return err
+		}
+		if err := encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(out)), sum); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return f.Close()
+	case cfg.sqlite != "":
+		output, err := openSQLiteOutput(cfg.sqlite)
+		if err != nil {
+			return err
+		}
+		return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(documentFormat{output: output, opts: cfg.docOptions})), sum)
+	case cfg.postgres != "":
+		output, err := openPostgresOutput(cfg.postgres, cfg.postgresTable, cfg.postgresBatchSize)
+		if err != nil {
+			return err
+		}
+		return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(documentFormat{output: output, opts: cfg.docOptions})), sum)
+	case cfg.otlpEndpoint != "":
+		output, err := newOTLPOutput(ctx, nil, cfg.otlpEndpoint)
+		if err != nil {
+			return err
+		}
+		return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(documentFormat{output: output, opts: cfg.docOptions})), sum)
+	case cfg.es.URL == "":
+		out, err := newOutputFormat(cfg.format, stdout, cfg.es, nil, cfg.docOptions)
+		if err != nil {
+			return err
+		}
+		return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(out)), sum)
 	}
-	if user != "" || pass != "" {
-		req.SetBasicAuth(user, pass)
+
+	if cfg.dryRun {
+		return dryRun(cfg, stdin, stdout, check, sum)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	indexer, err := gobench.NewIndexer(ctx, cfg.es)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var esVersion struct {
-		Version struct {
-			Number string
-		} `json:"version"`
+	sum.es = true
+	defer func() {
+		sum.indexed, sum.failed = indexer.Indexed(), indexer.Failed()
+	}()
+	if cfg.uploadFile != "" {
+		if err := indexer.UploadFile(cfg.uploadFile); err != nil {
+			return err
+		}
+		return indexer.Flush()
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("received unexpected %d status code", resp.StatusCode)
+	var output gobench.Output = indexer
+	if *verboseFlag {
+		output = multiOutput{indexer, gobench.NewBulkOutput(stdout, cfg.es, indexer.Version())}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&esVersion); err != nil {
-		return nil, err
+	if cfg.separateUploads && len(cfg.inputFiles) > 0 {
+		return uploadSeparately(cfg, indexer, output, check, sum)
 	}
-	return semver.New(esVersion.Version.Number)
+	out := documentFormat{output: output, opts: cfg.docOptions}
+	return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(out)), sum)
 }
 
-func encodeIndexOp(
-	encoder *json.Encoder,
-	b benchmark,
-	pkg, goos, goarch string,
-	tags map[string]string,
-	timestamp time.Time,
-	cfg elasticsearchConfig,
-) {
-	doc := map[string]interface{}{
-		fieldExecutedAt: timestamp,
-		fieldName:       b.Name,
-		fieldIterations: b.N,
-		fieldPkg:        pkg,
-		fieldGoVersion:  runtime.Version(),
-		fieldGOOS:       goos,
-		fieldGOARCH:     goarch,
-	}
-	if b.Measured&parse.NsPerOp != 0 {
-		doc[fieldNSPerOp] = b.NsPerOp
-	}
-	if b.Measured&parse.MBPerS != 0 {
-		doc[fieldMBPerS] = b.MBPerS
-	}
-	if b.Measured&parse.AllocedBytesPerOp != 0 {
-		doc[fieldAllocedBytesPerOp] = b.AllocedBytesPerOp
-	}
-	if b.Measured&parse.AllocsPerOp != 0 {
-		doc[fieldAllocsPerOp] = b.AllocsPerOp
-	}
-	if len(b.extra) > 0 {
-		apmbench := b.extra
-		doc[fieldExtraMetrics] = apmbench
+// uploadSeparately indexes the results of each of cfg.inputFiles in turn,
+// writing them to output, and ending the bulk request of indexer after
+// each file. The number of documents indexed from each file is logged.
+func uploadSeparately(
+	cfg inputConfig,
+	indexer *gobench.Indexer,
+	output gobench.Output,
+	check *baselineCheck,
+	sum *summary,
+) error {
+	if cfg.timestamp.IsZero() {
+		// Results of all files have the same execution time.
+		cfg.timestamp = time.Now().UTC()
+	}
+	out := documentFormat{output: endRequestOutput{Output: output, indexer: indexer}, opts: cfg.docOptions}
+	for _, path := range cfg.inputFiles {
+		fileCfg := cfg
+		fileCfg.inputFiles = []string{path}
+		indexed, failed := indexer.Indexed(), indexer.Failed()
+		if err := encodeBenchmarks(fileCfg, nil, check.wrap(sum.wrap(out)), sum); err != nil {
+			return err
+		}
+		slog.Info("uploaded input file",
+			"input", path,
+			"indexed", indexer.Indexed()-indexed,
+			"failed", indexer.Failed()-failed,
+		)
 	}
+	return output.Flush()
+}
 
-	addHost(doc)
-	addVCS(pkg, doc)
-	for key, value := range tags {
-		doc[key] = value
-	}
+// endRequestOutput is an Output whose Flush ends the current bulk request
+// of indexer, rather than flushing Output.
+type endRequestOutput struct {
+	gobench.Output
+	indexer *gobench.Indexer
+}
 
-	// Versions of Elasticsearch >= 8.0.0 require no _type field
-	esVersion, err := getEsVersion(cfg.host, cfg.user, cfg.pass)
-	if err != nil {
-		log.Fatal(err)
-	}
-	includeTypDoc := esVersion.LT(semver.MustParse("8.0.0"))
+func (o endRequestOutput) Flush() error {
+	o.indexer.EndRequest()
+	return nil
+}
 
-	type Index struct {
-		Index string `json:"_index"`
-		Type  string `json:"_type,omitempty"`
-	}
-	indexAction := struct {
-		Index Index `json:"index"`
-	}{Index: Index{
-		Index: cfg.index,
-	}}
-	if includeTypDoc {
-		indexAction.Index.Type = "_doc"
+// dryRun writes the bulk request body that run would send to Elasticsearch
+// to stdout. No requests are made, so the latest Elasticsearch version is
+// assumed.
+func dryRun(cfg inputConfig, stdin io.Reader, stdout io.Writer, check *baselineCheck, sum *summary) error {
+	if cfg.uploadFile != "" {
+		return gobench.CopyBulkFile(stdout, cfg.uploadFile, cfg.es)
 	}
+	out := documentFormat{output: gobench.NewBulkOutput(stdout, cfg.es, nil), opts: cfg.docOptions}
+	return encodeBenchmarks(cfg, stdin, check.wrap(sum.wrap(out)), sum)
+}
 
-	if err := encoder.Encode(indexAction); err != nil {
-		log.Fatal(err)
+// encodeBenchmarks parses benchmark output from each of cfg.inputFiles in
+// turn or, if there are none, from r, encoding each benchmark with out and
+// counting the lines and benchmarks parsed in sum.
+func encodeBenchmarks(
+	cfg inputConfig,
+	r io.Reader,
+	out outputFormat,
+	sum *summary,
+) error {
+	timestamp := cfg.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+	if cfg.es.SortByTime {
+		out = newSortedFormat(out)
+	}
+	if cfg.aggregate {
+		out = newAggregateFormat(out)
+	}
+	if len(cfg.inputFiles) == 0 {
+		if err := encodeInput(cfg, "stdin", r, out, sum, timestamp); err != nil {
+			return err
+		}
+		return out.flush()
 	}
-	if err := encoder.Encode(doc); err != nil {
-		log.Fatal(err)
+	for _, path := range cfg.inputFiles {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = encodeInput(cfg, path, f, out, sum, timestamp)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s", path)
+		}
 	}
+	return out.flush()
 }
 
-func handleResponse(resp *http.Response) error {
-	defer resp.Body.Close()
-	if !*verboseFlag && resp.StatusCode == http.StatusOK {
-		return nil
-	}
-	result := make(map[string]interface{})
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Fatal(err)
-	}
-	if resp.StatusCode == http.StatusOK {
-		pretty.Println(result)
-		return nil
-	}
-	errorObj, ok := result["error"].(map[string]interface{})
-	if !ok {
-		return errors.Errorf("%s", resp.Status)
+// encodeInput parses benchmark output from r, which is decompressed if
+// gzipped, encoding each benchmark with out. The pkg, goos, goarch and cpu headers apply only to the
+// subsequent lines of r. Lines which cannot be parsed are logged at debug
+// level, along with name and their line number.
+func encodeInput(
+	cfg inputConfig,
+	name string,
+	r io.Reader,
+	out outputFormat,
+	sum *summary,
+	timestamp time.Time,
+) error {
+	r, err := gunzipInput(r, cfg.inputGzip)
+	if err != nil {
+		return err
 	}
-	return &esError{
-		Type:   errorObj["type"].(string),
-		Reason: errorObj["reason"].(string),
+	var p gobench.Parser
+	p.OnError = func(lineNum int, line string, err error) {
+		slog.Debug("error parsing benchmark result",
+			"input", name,
+			"line", lineNum,
+			"text", line,
+			"error", err,
+		)
+	}
+	defer func() {
+		sum.lines += p.Lines
+		sum.benchmarks += p.Benchmarks
+		sum.parseErrors += p.Errors
+		sum.testFailed = sum.testFailed || p.Failed
+	}()
+	encode := func(result gobench.Result) error {
+		if cfg.minNsPerOp > 0 && result.Measured&parse.NsPerOp != 0 && result.NsPerOp < cfg.minNsPerOp {
+			sum.skipped++
+			return nil
+		}
+		result.Extra = filterExtraMetrics(result.Extra, cfg.extraMetrics, cfg.extraMetricsExclude)
+		return out.encode(
+			result.Benchmark,
+			result.Pkg, result.GOOS, result.GOARCH, result.CPU,
+			cfg.tags, timestamp,
+		)
+	}
+	if cfg.input == inputJSON {
+		return forEachTestEventLine(r, func(eventPkg, line string) error {
+			if eventPkg != "" {
+				p.Pkg = eventPkg
+			}
+			if result, ok := p.ParseLine(line); ok {
+				return encode(result)
+			}
+			return nil
+		})
 	}
+	return p.Parse(r, encode)
 }
 
-func addHost(doc map[string]interface{}) {
-	if hostname, err := os.Hostname(); err == nil {
-		doc[fieldHostname] = hostname
-	}
-	switch runtime.GOOS {
-	case "linux":
-		if output, err := exec.Command("uname", "-r").Output(); err == nil {
-			doc[fieldOSVersion] = strings.TrimSpace(string(output))
-		}
+// newRunID returns a random (version 4) UUID identifying a run.
+func newRunID() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", errors.Wrap(err, "error generating run ID")
 	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
 }
 
-func addVCS(pkgpath string, doc map[string]interface{}) {
-	pkg, err := build.Import(pkgpath, "", build.FindOnly)
-	if err != nil {
-		return
+// documentFields returns the fields added to each document for cfg, which
+// are the same for all benchmarks of a run.
+func documentFields(cfg inputConfig) gobench.Document {
+	fields := gobench.Document{
+		gobench.FieldGobenchVersion: readBuildInfo().versionString(),
 	}
-	vcsCmd, _, err := vcs.FromDir(pkg.Dir, pkg.SrcRoot)
-	if err != nil {
-		return
+	if cfg.benchtime != "" {
+		fields[gobench.FieldBenchtime] = cfg.benchtime
 	}
-
-	switch vcsCmd.Cmd {
-	case "git":
-		cmd := exec.Command("git", "log", "-1", "--format=%H %ct %s")
-		cmd.Dir = pkg.Dir
-		output, err := cmd.CombinedOutput()
+	if cfg.testBinary != "" {
+		// Build settings are a best-effort enrichment, like host details.
+		settings, err := gobench.ReadBuildSettings(cfg.testBinary)
 		if err != nil {
-			return
-		}
-		fields := strings.SplitN(strings.TrimSpace(string(output)), " ", 3)
-		if len(fields) == 3 {
-			gitFields := map[string]interface{}{
-				fieldGitCommit:  fields[0],
-				fieldGitSubject: fields[2],
-			}
-			unixSec, err := strconv.ParseInt(fields[1], 10, 64)
-			if err == nil {
-				committerDate := time.Unix(unixSec, 0).UTC()
-				gitFields[fieldGitCommitter] = map[string]interface{}{
-					fieldGitCommitterDate: committerDate,
-				}
-			}
-			doc[fieldGit] = gitFields
+			slog.Warn("skipping build settings", "error", err)
+		} else if len(settings) > 0 {
+			fields[gobench.FieldBuildSettings] = settings
 		}
 	}
+	return fields
 }
 
-func parseExtraMetrics(line string) map[string]float64 {
-	entries := strings.Split(line, "\t")
-	// If the result has less than 3 columns, it doesn't contain
-	// extra metrics to be reported.
-	if len(entries) < 3 {
-		return nil
+// filterExtraMetrics returns the extra metrics whose keys are in include,
+// if it is non-empty, and not in exclude.
+func filterExtraMetrics(extra map[string]float64, include, exclude []string) map[string]float64 {
+	if len(include) == 0 && len(exclude) == 0 {
+		return extra
 	}
-
-	result := make(map[string]float64)
-	// Ignore the first three entries since they're fixed to be the benchmark,
-	// name, iterations and ns/op.
-	for _, entry := range entries[3:] {
-		parts := strings.Split(strings.TrimSpace(entry), " ")
-		if len(parts) < 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[1])
-		value, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
-		if err != nil {
+	filtered := make(map[string]float64)
+	for key, value := range extra {
+		if len(include) > 0 && !containsString(include, key) {
 			continue
 		}
-		switch key {
-		case "ns/op", "MB/s", "B/op", "allocs/op":
-			// Ignore the native benchmark fields
+		if containsString(exclude, key) {
 			continue
-		default:
-			escapedKey := strings.ReplaceAll(key, "/", "_")
-			result[escapedKey] = value
 		}
+		filtered[key] = value
 	}
-	if len(result) > 0 {
-		return result
+	if len(filtered) == 0 {
+		return nil
 	}
-	return nil
+	return filtered
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
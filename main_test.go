@@ -19,100 +19,643 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/elastic/gobench/gobench"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func Test_parseExtraMetrics(t *testing.T) {
-	f, err := os.Open("testdata/benchmark-result.txt")
-	if err != nil {
-		t.Fatal(err)
-	}
+func Test_runOutputFile(t *testing.T) {
+	input, err := os.Open("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	defer input.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "bulk.ndjson")
+	var stdout bytes.Buffer
+	err = run(context.Background(), inputConfig{es: gobench.Config{Index: "gobench"}, outputFile: outputFile}, input, &stdout)
+	require.NoError(t, err)
+	assert.Zero(t, stdout.Len())
+
+	f, err := os.Open(outputFile)
+	require.NoError(t, err)
 	defer f.Close()
-	type args struct {
-		line string
-	}
-	expected := []map[string]float64{
-		{
-			"error_responses_sec": 0,
-			"errors_sec":          320.7,
-			"events_sec":          15988,
-			"metrics_sec":         735.5,
-			"spans_sec":           10546,
-			"txs_sec":             4386},
-		{
-			"error_responses_sec": 0,
-			"errors_sec":          293.8,
-			"events_sec":          12066,
-			"metrics_sec":         716.6,
-			"spans_sec":           6361,
-			"txs_sec":             4695},
-		{
-			"error_responses_sec": 0,
-			"errors_sec":          132.6,
-			"events_sec":          12928,
-			"metrics_sec":         3899,
-			"spans_sec":           7512,
-			"txs_sec":             1385},
-		{
-			"error_responses_sec": 0,
-			"errors_sec":          503.9,
-			"events_sec":          14116,
-			"metrics_sec":         1037,
-			"spans_sec":           8303,
-			"txs_sec":             4272},
-		nil, // Second to last entry is ignored.
-		nil, // Last entry is ignored.
-	}
+	var lines int
 	scanner := bufio.NewScanner(f)
-	for i := 0; scanner.Scan(); i++ {
-		result := parseExtraMetrics(scanner.Text())
-		if len(expected) <= i {
-			t.Errorf("expected entry not found for index %d", i)
-			return
+	for ; scanner.Scan(); lines++ {
+		var obj map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &obj), "line %d", lines)
+		if lines%2 == 0 {
+			assert.Contains(t, obj, "index")
+		} else {
+			assert.Contains(t, obj, gobench.FieldName)
 		}
-		assert.Equal(t, expected[i], result)
 	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, 12, lines) // 6 benchmarks, each with an action and document
 }
 
-func Test_getEsVersion(t *testing.T) {
-	t.Run("success", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-			w.Write([]byte(`{"version" : {"number" : "7.11.1"}}`))
-		}))
-		t.Cleanup(srv.Close)
-		v, err := getEsVersion(srv.URL, "", "")
-		require.NoError(t, err)
-		require.NotNil(t, v)
-		assert.Equal(t, "7.11.1", v.String())
+func Test_runDryRun(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	input, err := os.Open("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	defer input.Close()
+
+	var stdout bytes.Buffer
+	cfg := inputConfig{es: gobench.Config{URL: srv.URL, Index: "gobench"}, dryRun: true}
+	require.NoError(t, run(context.Background(), cfg, input, &stdout))
+	assert.Zero(t, requests)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 12)
+	var action map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &action))
+	assert.Equal(t, "gobench", action["index"]["_index"])
+}
+
+// failingReader is an io.Reader which fails the test if it is read.
+type failingReader struct{ t *testing.T }
+
+func (r failingReader) Read([]byte) (int, error) {
+	r.t.Error("unexpected read of input")
+	return 0, io.EOF
+}
+
+func Test_runConnectionCheck(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status int
+		err    string
+	}{
+		"unauthorized": {status: http.StatusUnauthorized, err: "received unexpected 401 status code"},
+		"forbidden":    {status: http.StatusForbidden, err: "received unexpected 403 status code"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			cfg := inputConfig{es: gobench.Config{URL: srv.URL, Index: "gobench"}}
+			err := run(context.Background(), cfg, failingReader{t}, io.Discard)
+			assert.EqualError(t, err, "error connecting to Elasticsearch at "+srv.URL+": "+tc.err)
+			assert.Equal(t, 1, requests)
+		})
+	}
+	t.Run("unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		cfg := inputConfig{es: gobench.Config{URL: srv.URL, Index: "gobench"}}
+		err := run(context.Background(), cfg, failingReader{t}, io.Discard)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "error connecting to Elasticsearch at "+srv.URL)
 	})
-	t.Run("success-auth", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			user, password, ok := r.BasicAuth()
-			require.True(t, ok)
-			assert.Equal(t, "myuser", user)
-			assert.Equal(t, "mypassword", password)
-			w.Write([]byte(`{"version" : {"number" : "7.11.1"}}`))
-		}))
-		t.Cleanup(srv.Close)
-		v, err := getEsVersion(srv.URL, "myuser", "mypassword")
+}
+
+// encodeDocs encodes the benchmarks in input as bulk actions, and returns
+// the decoded documents.
+func encodeDocs(t testing.TB, cfg inputConfig, input string) []map[string]interface{} {
+	var buf bytes.Buffer
+	out, err := newOutputFormat(formatJSON, &buf, cfg.es, nil, cfg.docOptions)
+	require.NoError(t, err)
+	require.NoError(t, encodeBenchmarks(cfg, strings.NewReader(input), out, new(summary)))
+
+	var docs []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for {
+		var action, doc map[string]interface{}
+		if err := decoder.Decode(&action); err == io.EOF {
+			break
+		}
 		require.NoError(t, err)
-		require.NotNil(t, v)
-		assert.Equal(t, "7.11.1", v.String())
-	})
-	t.Run("fail-401", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
-			w.WriteHeader(401)
-			w.Write([]byte(`{"error":{"root_cause":[{"type":"security_exception","reason":"missing authentication credentials for REST request [/]","header":{"WWW-Authenticate":["Basic realm=\"security\" charset=\"UTF-8\"","Bearer realm=\"security\"","ApiKey"]}}],"type":"security_exception","reason":"missing authentication credentials for REST request [/]","header":{"WWW-Authenticate":["Basic realm=\"security\" charset=\"UTF-8\"","Bearer realm=\"security\"","ApiKey"]}},"status":401}`))
-		}))
-		t.Cleanup(srv.Close)
-		v, err := getEsVersion(srv.URL, "", "")
-		assert.EqualError(t, err, "received unexpected 401 status code")
-		assert.Nil(t, v)
+		require.NoError(t, decoder.Decode(&doc))
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// recordingOutput is a gobench.Output which records the documents
+// written to it, and the number of times it is flushed.
+type recordingOutput struct {
+	docs    []gobench.Document
+	flushes int
+}
+
+func (o *recordingOutput) Write(doc gobench.Document) error {
+	o.docs = append(o.docs, doc)
+	return nil
+}
+
+func (o *recordingOutput) Flush() error {
+	o.flushes++
+	return nil
+}
+
+func Test_encodeBenchmarksOutput(t *testing.T) {
+	input, err := os.Open("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	defer input.Close()
+
+	var output recordingOutput
+	var sum summary
+	require.NoError(t, encodeBenchmarks(inputConfig{}, input, documentFormat{output: &output}, &sum))
+	assert.Equal(t, 6, sum.benchmarks)
+	require.Len(t, output.docs, sum.benchmarks)
+	assert.Equal(t, "BenchmarkAgentGo", output.docs[0][gobench.FieldName])
+	assert.Equal(t, 1, output.flushes)
+}
+
+func Test_encodeBenchmarksSubBenchmarks(t *testing.T) {
+	docs := encodeDocs(t, inputConfig{}, "BenchmarkX/a=1/b=2-8\t100\t12.5 ns/op\nBenchmarkX-8\t100\t12.5 ns/op\n")
+	require.Len(t, docs, 2)
+	assert.Equal(t, "BenchmarkX", docs[0][gobench.FieldName])
+	assert.Equal(t, "BenchmarkX/a=1/b=2", docs[0][gobench.FieldFullName])
+	assert.Equal(t, map[string]interface{}{"a": "1", "b": "2"}, docs[0][gobench.FieldParams])
+	assert.NotContains(t, docs[0], gobench.FieldSegments)
+
+	assert.Equal(t, "BenchmarkX", docs[1][gobench.FieldName])
+	assert.Equal(t, "BenchmarkX", docs[1][gobench.FieldFullName])
+	assert.NotContains(t, docs[1], gobench.FieldParams)
+}
+
+func Test_encodeBenchmarksInputFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	require.NoError(t, os.WriteFile(first, []byte("goos: linux\npkg: example.com/foo\nBenchmarkFoo-8\t100\t12.5 ns/op\n"), 0600))
+	require.NoError(t, os.WriteFile(second, []byte("BenchmarkBar-8\t100\t25 ns/op\npkg: example.com/baz\nBenchmarkBaz-8\t100\t50 ns/op\n"), 0600))
+
+	cfg := inputConfig{inputFiles: []string{first, second}}
+	docs := encodeDocs(t, cfg, "BenchmarkStdin-8\t100\t1 ns/op\n")
+	require.Len(t, docs, 3)
+	assert.Equal(t, "BenchmarkFoo", docs[0][gobench.FieldName])
+	assert.Equal(t, "example.com/foo", docs[0][gobench.FieldPkg])
+	assert.Equal(t, "linux", docs[0][gobench.FieldGOOS])
+	// Headers from the first file do not apply to the second.
+	assert.Equal(t, "BenchmarkBar", docs[1][gobench.FieldName])
+	assert.Equal(t, "", docs[1][gobench.FieldPkg])
+	assert.Equal(t, "", docs[1][gobench.FieldGOOS])
+	assert.Equal(t, "BenchmarkBaz", docs[2][gobench.FieldName])
+	assert.Equal(t, "example.com/baz", docs[2][gobench.FieldPkg])
+}
+
+func Test_encodeBenchmarksTimestamp(t *testing.T) {
+	timestamp := time.Date(2024, time.January, 15, 9, 30, 0, 0, time.UTC)
+	docs := encodeDocs(t, inputConfig{timestamp: timestamp}, "BenchmarkFoo-8\t100\t12.5 ns/op\n")
+	require.Len(t, docs, 1)
+	assert.Equal(t, "2024-01-15T09:30:00Z", docs[0][gobench.FieldExecutedAt])
+}
+
+func Test_encodeBenchmarksCPU(t *testing.T) {
+	docs := encodeDocs(t, inputConfig{}, `goos: linux
+goarch: amd64
+BenchmarkNoCPU-8   	 1000	      1000 ns/op
+cpu: Intel(R) Xeon(R) CPU @ 2.20GHz
+BenchmarkFoo-8   	 1000	      1000 ns/op
+BenchmarkBar-8   	 1000	      1000 ns/op
+cpu: AMD EPYC 7B12
+BenchmarkBaz-8   	 1000	      1000 ns/op
+`)
+	require.Len(t, docs, 4)
+	assert.NotContains(t, docs[0], gobench.FieldCPU)
+	assert.Equal(t, "Intel(R) Xeon(R) CPU @ 2.20GHz", docs[1][gobench.FieldCPU])
+	assert.Equal(t, "Intel(R) Xeon(R) CPU @ 2.20GHz", docs[2][gobench.FieldCPU])
+	assert.Equal(t, "AMD EPYC 7B12", docs[3][gobench.FieldCPU])
+}
+
+func Test_runUploadFile(t *testing.T) {
+	var mu sync.Mutex
+	var docs []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			w.Write([]byte(`{"version" : {"number" : "8.1.0"}}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/gobench":
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			decoder := json.NewDecoder(r.Body)
+			for {
+				var action, doc map[string]interface{}
+				if err := decoder.Decode(&action); err == io.EOF {
+					break
+				}
+				require.NoError(t, decoder.Decode(&doc))
+				assert.Contains(t, action, "index")
+				mu.Lock()
+				docs = append(docs, doc)
+				mu.Unlock()
+			}
+			w.Write([]byte(`{"took":1,"errors":false}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// Generate the NDJSON file, and then upload it.
+	input, err := os.Open("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	defer input.Close()
+	outputFile := filepath.Join(t.TempDir(), "bulk.ndjson")
+	err = run(context.Background(), inputConfig{es: gobench.Config{Index: "gobench"}, outputFile: outputFile}, input, io.Discard)
+	require.NoError(t, err)
+
+	cfg := inputConfig{
+		es:         gobench.Config{URL: srv.URL, Index: "gobench", BulkMaxBytes: 1024},
+		uploadFile: outputFile,
+	}
+	err = run(context.Background(), cfg, strings.NewReader("stdin should not be read"), io.Discard)
+	require.NoError(t, err)
+
+	require.Len(t, docs, 6)
+	names := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		names[i] = doc[gobench.FieldName]
+	}
+	assert.Equal(t, []interface{}{
+		"BenchmarkAgentGo",
+		"BenchmarkAgentNodeJS",
+		"BenchmarkAgentPython",
+		"BenchmarkAgentRuby",
+		"BenchmarkOther",
+		"BenchmarkOtherNoAPMBench",
+	}, names)
+}
+
+func Test_encodeBenchmarksExtraMetrics(t *testing.T) {
+	const input = "BenchmarkFoo-8\t100\t12.5 ns/op\t1 errors/sec\t2 events/sec\t3 spans/sec\n"
+	for name, tc := range map[string]struct {
+		args     []string
+		expected interface{}
+	}{
+		"default": {
+			expected: map[string]interface{}{"errors_sec": 1.0, "events_sec": 2.0, "spans_sec": 3.0},
+		},
+		"allow": {
+			args:     []string{"-extra-metrics", "events/sec,spans_sec"},
+			expected: map[string]interface{}{"events_sec": 2.0, "spans_sec": 3.0},
+		},
+		"exclude": {
+			args:     []string{"-extra-metrics-exclude", "errors/sec"},
+			expected: map[string]interface{}{"events_sec": 2.0, "spans_sec": 3.0},
+		},
+		"allow and exclude": {
+			args:     []string{"-extra-metrics", "events/sec,spans/sec", "-extra-metrics-exclude", "spans/sec"},
+			expected: map[string]interface{}{"events_sec": 2.0},
+		},
+		"none kept": {
+			args: []string{"-extra-metrics", "txs/sec"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := testReadInputConfig(t, tc.args...)
+			require.NoError(t, err)
+			docs := encodeDocs(t, cfg, input)
+			require.Len(t, docs, 1)
+			assert.Equal(t, tc.expected, docs[0][gobench.FieldExtraMetrics])
+		})
+	}
+}
+
+func Test_encodeBenchmarksTags(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-tag", "name=x", "-tag", "team=apm")
+	require.NoError(t, err)
+	docs := encodeDocs(t, cfg, "BenchmarkFoo-8\t100\t12.5 ns/op\n")
+	require.Len(t, docs, 1)
+	assert.Equal(t, "BenchmarkFoo", docs[0][gobench.FieldName])
+	assert.Equal(t, map[string]interface{}{"name": "x", "team": "apm"}, docs[0][gobench.FieldTags])
+	assert.NotContains(t, docs[0], "team")
+}
+
+// testGobenchMain runs gobenchMain with args and stdin, returning the exit
+// code and what was written to stdout and stderr.
+func testGobenchMain(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	orig := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(orig)
+		*verboseFlag = false
 	})
+	fs := flag.NewFlagSet("gobench", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var outBuf, errBuf bytes.Buffer
+	code = gobenchMain(fs, args, strings.NewReader(stdin), &outBuf, &errBuf)
+	return code, outBuf.String(), errBuf.String()
+}
+
+func Test_gobenchMainQuiet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version":{"number":"8.0.0"}}`))
+		case "/_bulk":
+			w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`))
+		default:
+			w.Write([]byte(`{"acknowledged":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	code, stdout, stderr := testGobenchMain(t, summaryInput, "-es", srv.URL)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "run complete")
+
+	code, stdout, stderr = testGobenchMain(t, summaryInput, "-quiet", "-es", srv.URL)
+	assert.Equal(t, 0, code)
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+}
+
+func Test_gobenchMainQuietError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"type":"security_exception","reason":"missing authentication credentials"}}`))
+	}))
+	defer srv.Close()
+
+	code, stdout, stderr := testGobenchMain(t, summaryInput, "-quiet", "-es", srv.URL)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "level=ERROR msg=\"gobench failed\"")
+	assert.NotContains(t, stderr, "run complete")
+
+	code, _, stderr = testGobenchMain(t, "", "-quiet", "-v")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "-quiet and -v are mutually exclusive\n", stderr)
+}
+
+func Test_gobenchMainExitCode(t *testing.T) {
+	for name, tc := range map[string]struct {
+		bulkResponse string
+		code         int
+	}{
+		"success": {
+			bulkResponse: `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":201}}]}`,
+			code:         exitOK,
+		},
+		"partial failure": {
+			bulkResponse: `{"errors":true,"items":[
+				{"index":{"status":201}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}
+			]}`,
+			code: exitPartialFailure,
+		},
+		"total failure": {
+			bulkResponse: `{"errors":true,"items":[
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}
+			]}`,
+			code: exitFailure,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					w.Write([]byte(`{"version":{"number":"8.0.0"}}`))
+				case "/_bulk":
+					w.Write([]byte(tc.bulkResponse))
+				default:
+					w.Write([]byte(`{"acknowledged":true}`))
+				}
+			}))
+			defer srv.Close()
+
+			code, _, _ := testGobenchMain(t, summaryInput, "-quiet", "-es", srv.URL)
+			assert.Equal(t, tc.code, code)
+		})
+	}
+
+	code, _, _ := testGobenchMain(t, "", "-workers", "0")
+	assert.Equal(t, exitUsage, code)
+}
+
+func Test_documentFieldsBuildSettings(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	// The test binary is itself a Go binary with build settings.
+	testBinary, err := os.Executable()
+	require.NoError(t, err)
+
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}, testBinary: testBinary}
+	cfg.docOptions.Fields = documentFields(cfg)
+	docs := encodeDocs(t, cfg, "BenchmarkFoo-8\t100\t10 ns/op\n")
+	require.Len(t, docs, 1)
+	settings, ok := docs[0]["build_settings"].(map[string]interface{})
+	require.True(t, ok, "build_settings missing: %v", docs[0])
+	assert.Equal(t, runtime.GOOS, settings["GOOS"])
+	assert.Equal(t, runtime.GOARCH, settings["GOARCH"])
+	assert.NotContains(t, settings, "vcs.revision")
+
+	cfg.testBinary = filepath.Join(t.TempDir(), "missing.test")
+	assert.NotContains(t, documentFields(cfg), gobench.FieldBuildSettings)
+}
+
+func Test_documentFieldsBenchtime(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-benchtime", "100x", "-no-vcs", "-no-host")
+	require.NoError(t, err)
+	cfg.docOptions.Fields = documentFields(cfg)
+	docs := encodeDocs(t, cfg, "BenchmarkFoo-8\t100\t10 ns/op\nBenchmarkBar-8\t100\t20 ns/op\n")
+	require.Len(t, docs, 2)
+	for _, doc := range docs {
+		assert.Equal(t, "100x", doc["benchtime"])
+	}
+
+	cfg, err = testReadInputConfig(t, "-no-vcs", "-no-host")
+	require.NoError(t, err)
+	cfg.docOptions.Fields = documentFields(cfg)
+	docs = encodeDocs(t, cfg, "BenchmarkFoo-8\t100\t10 ns/op\n")
+	require.Len(t, docs, 1)
+	assert.NotContains(t, docs[0], "benchtime")
+}
+
+func Test_encodeBenchmarksNoVCS(t *testing.T) {
+	const input = "pkg: github.com/elastic/gobench\nBenchmarkFoo-8\t100\t10 ns/op\n"
+
+	cfg, err := testReadInputConfig(t)
+	require.NoError(t, err)
+	docs := encodeDocs(t, cfg, input)
+	require.Len(t, docs, 1)
+	assert.Contains(t, docs[0], "git")
+
+	cfg, err = testReadInputConfig(t, "-no-vcs", "-no-host")
+	require.NoError(t, err)
+	docs = encodeDocs(t, cfg, input)
+	require.Len(t, docs, 1)
+	assert.NotContains(t, docs[0], "git")
+	assert.NotContains(t, docs[0], "hostname")
+}
+
+func Test_gobenchMainDedupSequence(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	const input = "pkg: example.com/foo\nBenchmarkFoo-8\t100\t10 ns/op\nBenchmarkFoo-8\t100\t11 ns/op\nBenchmarkFoo-8\t100\t12 ns/op\n"
+	// The commit, on which IDs depend, is read from this repository.
+	code, stdout, stderr := testGobenchMain(t, input,
+		"-es", "http://127.0.0.1:0", "-dry-run", "-no-host", "-repo-dir", ".",
+		"-dedup", "-dedup-sequence",
+	)
+	require.Equal(t, exitOK, code, stderr)
+
+	ids := make(map[string]float64)
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	for decoder.More() {
+		var action, doc map[string]interface{}
+		require.NoError(t, decoder.Decode(&action))
+		require.NoError(t, decoder.Decode(&doc))
+		id, _ := action["index"].(map[string]interface{})["_id"].(string)
+		require.NotEmpty(t, id, "document without an ID; is this a git checkout?")
+		ids[id] = doc["ns_per_op"].(float64)
+	}
+	assert.Len(t, ids, 3)
+}
+
+func Test_gobenchMainFailOnEmpty(t *testing.T) {
+	const headers = "goos: linux\ngoarch: amd64\npkg: github.com/elastic/gobench\nPASS\n"
+
+	code, _, stderr := testGobenchMain(t, headers, "-fail-on-empty")
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stderr, "no benchmarks parsed from 4 lines of input")
+
+	code, _, stderr = testGobenchMain(t, "", "-fail-on-empty")
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stderr, "no benchmarks parsed: the input was empty")
+
+	code, _, _ = testGobenchMain(t, headers)
+	assert.Equal(t, exitOK, code)
+
+	code, _, _ = testGobenchMain(t, summaryInput, "-fail-on-empty")
+	assert.Equal(t, exitOK, code)
+}
+
+func Test_gobenchMainSeparateUploads(t *testing.T) {
+	dir := t.TempDir()
+	linux := filepath.Join(dir, "linux.txt")
+	darwin := filepath.Join(dir, "darwin.txt")
+	require.NoError(t, os.WriteFile(linux, []byte("goos: linux\nBenchmarkFoo-8\t100\t12.5 ns/op\nBenchmarkBar-8\t100\t5 ns/op\n"), 0644))
+	require.NoError(t, os.WriteFile(darwin, []byte("goos: darwin\nBenchmarkFoo-8\t100\t11.5 ns/op\n"), 0644))
+
+	var bulk []string
+	srv := newBulkServer(t, &bulk)
+	for _, extra := range [][]string{nil, {"-workers", "2"}, {"-bulk-max-bytes", "0"}} {
+		bulk = nil
+		args := append([]string{"-es", srv.URL, "-no-host", "-no-vcs", "-separate-uploads"}, extra...)
+		code, _, stderr := testGobenchMain(t, "", append(args, linux, darwin)...)
+		require.Equal(t, exitOK, code, stderr)
+		require.Len(t, bulk, 2, extra)
+		assert.Equal(t, 2, strings.Count(bulk[0], `"goos":"linux"`), extra)
+		assert.Equal(t, 1, strings.Count(bulk[1], `"goos":"darwin"`), extra)
+		assert.Contains(t, stderr, `msg="uploaded input file" input=`+linux+` indexed=2 failed=0`)
+		assert.Contains(t, stderr, `msg="uploaded input file" input=`+darwin+` indexed=1 failed=0`)
+	}
+
+	bulk = nil
+	code, _, stderr := testGobenchMain(t, "", "-es", srv.URL, "-no-host", "-no-vcs", linux, darwin)
+	require.Equal(t, exitOK, code, stderr)
+	assert.Len(t, bulk, 1)
+
+	code, _, stderr = testGobenchMain(t, "", "-separate-uploads", linux)
+	assert.Equal(t, exitUsage, code)
+	assert.Equal(t, "-separate-uploads requires -es\n", stderr)
+}
+
+func Test_gobenchMainRunID(t *testing.T) {
+	runIDs := func() []string {
+		code, stdout, stderr := testGobenchMain(t, summaryInput, "-no-host", "-no-vcs")
+		require.Equal(t, exitOK, code, stderr)
+		var ids []string
+		decoder := json.NewDecoder(strings.NewReader(stdout))
+		for decoder.More() {
+			var action, doc map[string]interface{}
+			require.NoError(t, decoder.Decode(&action))
+			require.NoError(t, decoder.Decode(&doc))
+			ids = append(ids, doc[gobench.FieldRunID].(string))
+		}
+		require.NotEmpty(t, ids)
+		return ids
+	}
+	first := runIDs()
+	for _, id := range first {
+		assert.Equal(t, first[0], id)
+	}
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, first[0])
+	assert.NotEqual(t, first[0], runIDs()[0])
+}
+
+func Test_gobenchMainStoreRaw(t *testing.T) {
+	const line = "BenchmarkFoo-8   \t     100\t        10.5 ns/op\t  42 tokens/op"
+	raw := func(args ...string) []interface{} {
+		code, stdout, stderr := testGobenchMain(t, "pkg: example.com/foo\n"+line+"\n", append([]string{"-no-host", "-no-vcs"}, args...)...)
+		require.Equal(t, exitOK, code, stderr)
+		var raw []interface{}
+		decoder := json.NewDecoder(strings.NewReader(stdout))
+		for decoder.More() {
+			var action, doc map[string]interface{}
+			require.NoError(t, decoder.Decode(&action))
+			require.NoError(t, decoder.Decode(&doc))
+			raw = append(raw, doc[gobench.FieldRaw])
+		}
+		return raw
+	}
+	assert.Equal(t, []interface{}{line}, raw("-store-raw"))
+	assert.Equal(t, []interface{}{nil}, raw())
+}
+
+func Test_gobenchMainGzipInput(t *testing.T) {
+	documents := func(stdin string, args ...string) []map[string]interface{} {
+		args = append([]string{"-no-host", "-no-vcs", "-timestamp", "2024-01-15T10:00:00Z"}, args...)
+		code, stdout, stderr := testGobenchMain(t, stdin, args...)
+		require.Equal(t, exitOK, code, stderr)
+		var docs []map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(stdout))
+		for decoder.More() {
+			var doc map[string]interface{}
+			require.NoError(t, decoder.Decode(&doc))
+			delete(doc, gobench.FieldRunID)
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	_, err := zw.Write([]byte(summaryInput))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	path := filepath.Join(t.TempDir(), "benchmark.txt.gz")
+	require.NoError(t, os.WriteFile(path, gzipped.Bytes(), 0644))
+
+	expected := documents(summaryInput)
+	require.NotEmpty(t, expected)
+	assert.Equal(t, expected, documents(gzipped.String()))
+	assert.Equal(t, expected, documents("", path))
+	assert.Equal(t, expected, documents("", "-input-gzip", path))
+
+	code, _, stderr := testGobenchMain(t, summaryInput, "-input-gzip")
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stderr, "error reading gzipped input")
 }
@@ -0,0 +1,329 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/pkg/errors"
+)
+
+// otlpMetricsPath is the path to which OTLP/HTTP metrics are exported,
+// if the endpoint has no path.
+const otlpMetricsPath = "/v1/metrics"
+
+// otlpScopeName is the instrumentation scope of the exported metrics.
+const otlpScopeName = "github.com/elastic/gobench"
+
+// otlpMetricPrefix prefixes the names of the exported metrics.
+const otlpMetricPrefix = "gobench."
+
+// otlpMetrics are the benchmark fields exported as metrics, along with
+// their units.
+var otlpMetrics = []struct {
+	field string
+	unit  string
+}{
+	{gobench.FieldIterations, "{iteration}"},
+	{gobench.FieldNSPerOp, "ns"},
+	{gobench.FieldMBPerS, "MBy/s"},
+	{gobench.FieldAllocedBytesPerOp, "By"},
+	{gobench.FieldAllocsPerOp, "{allocation}"},
+}
+
+// otlpOutput is an Output which exports each document's metrics as OTLP
+// gauge data points, in a single OTLP/HTTP request sent by Flush.
+//
+// Metrics are named after their document fields, prefixed by "gobench.",
+// e.g. gobench.ns_per_op; extra metrics are named after their keys, e.g.
+// gobench.extra_metrics.events_sec. Data points are attributed with the
+// benchmark's package, name, goos, goarch and cpu, and with its tags
+// prefixed by "tags.". Documents are grouped into resources by their
+// hostname and commit.
+type otlpOutput struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+
+	// resources holds the metrics of each resource, in the order in
+	// which they were first seen, and byKey the same keyed by
+	// otlpResourceKey.
+	resources []*otlpResourceMetrics
+	byKey     map[string]*otlpResourceMetrics
+}
+
+// newOTLPOutput returns an otlpOutput which exports metrics with client to
+// the OTLP/HTTP endpoint, e.g. http://localhost:4318. If client is nil,
+// http.DefaultClient is used.
+func newOTLPOutput(ctx context.Context, client *http.Client, endpoint string) (*otlpOutput, error) {
+	u, err := otlpMetricsURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &otlpOutput{
+		ctx:      ctx,
+		client:   client,
+		endpoint: u,
+		byKey:    make(map[string]*otlpResourceMetrics),
+	}, nil
+}
+
+// otlpMetricsURL returns the URL to which metrics are exported for an
+// OTLP/HTTP endpoint, appending otlpMetricsPath if it has no path.
+func otlpMetricsURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Errorf("invalid OTLP endpoint %q: %s", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", errors.Errorf("invalid OTLP endpoint %q: must be an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpMetricsPath
+	}
+	return u.String(), nil
+}
+
+func (o *otlpOutput) Write(doc gobench.Document) error {
+	hostname, _ := doc[gobench.FieldHostname].(string)
+	commit, _ := docCommit(doc)
+	commitStr, _ := commit.(string)
+	resource := o.resource(hostname, commitStr)
+
+	timestamp, _ := doc[gobench.FieldExecutedAt].(time.Time)
+	attrs := otlpDocumentAttributes(doc)
+	for _, metric := range otlpMetrics {
+		if value, ok := doc[metric.field]; ok {
+			resource.addDataPoint(otlpMetricPrefix+metric.field, metric.unit, otlpDataPoint(attrs, timestamp, value))
+		}
+	}
+	extra, _ := doc[gobench.FieldExtraMetrics].(map[string]float64)
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := otlpMetricPrefix + gobench.FieldExtraMetrics + "." + key
+		resource.addDataPoint(name, "", otlpDataPoint(attrs, timestamp, extra[key]))
+	}
+	return nil
+}
+
+// resource returns the metrics of the resource identified by hostname and
+// commit, adding it if necessary.
+func (o *otlpOutput) resource(hostname, commit string) *otlpResourceMetrics {
+	key := hostname + "\x00" + commit
+	if r, ok := o.byKey[key]; ok {
+		return r
+	}
+	attrs := []otlpKeyValue{otlpString("service.name", "gobench")}
+	if hostname != "" {
+		attrs = append(attrs, otlpString("host.name", hostname))
+	}
+	if commit != "" {
+		attrs = append(attrs, otlpString("vcs.ref.head.revision", commit))
+	}
+	r := &otlpResourceMetrics{
+		Resource:     otlpResource{Attributes: attrs},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otlpScopeName}}},
+		byName:       make(map[string]*otlpMetric),
+	}
+	o.resources = append(o.resources, r)
+	o.byKey[key] = r
+	return r
+}
+
+// otlpDocumentAttributes returns the data point attributes of doc.
+func otlpDocumentAttributes(doc gobench.Document) []otlpKeyValue {
+	var attrs []otlpKeyValue
+	for _, field := range []string{
+		gobench.FieldPkg,
+		gobench.FieldName,
+		gobench.FieldFullName,
+		gobench.FieldGOOS,
+		gobench.FieldGOARCH,
+		gobench.FieldCPU,
+	} {
+		if value, _ := doc[field].(string); value != "" {
+			attrs = append(attrs, otlpString(field, value))
+		}
+	}
+	if gomaxprocs, ok := doc[gobench.FieldGOMAXPROCS].(int); ok {
+		attrs = append(attrs, otlpKeyValue{
+			Key:   gobench.FieldGOMAXPROCS,
+			Value: otlpAnyValue{IntValue: strconv.Itoa(gomaxprocs)},
+		})
+	}
+	tags, _ := doc[gobench.FieldTags].(map[string]string)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attrs = append(attrs, otlpString(gobench.FieldTags+"."+key, tags[key]))
+	}
+	return attrs
+}
+
+// otlpDataPoint returns a data point with the given attributes, timestamp
+// and value, which is an int, uint64 or float64.
+func otlpDataPoint(attrs []otlpKeyValue, timestamp time.Time, value interface{}) otlpNumberDataPoint {
+	dp := otlpNumberDataPoint{
+		Attributes:   attrs,
+		TimeUnixNano: strconv.FormatInt(timestamp.UnixNano(), 10),
+	}
+	switch value := value.(type) {
+	case int:
+		dp.AsInt = strconv.Itoa(value)
+	case uint64:
+		dp.AsInt = strconv.FormatUint(value, 10)
+	case float64:
+		dp.AsDouble = &value
+	}
+	return dp
+}
+
+// Flush exports the metrics of all documents written.
+func (o *otlpOutput) Flush() error {
+	if len(o.resources) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpExportRequest{ResourceMetrics: o.resources})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error exporting OTLP metrics")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "error exporting OTLP metrics")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("error exporting OTLP metrics: %s: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+	var result struct {
+		PartialSuccess struct {
+			RejectedDataPoints json.Number `json:"rejectedDataPoints"`
+			ErrorMessage       string      `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}
+	// The response body may be empty, or encoded as protobuf.
+	if json.Unmarshal(respBody, &result) == nil {
+		if rejected := result.PartialSuccess.RejectedDataPoints; rejected != "" && rejected != "0" {
+			return errors.Errorf(
+				"error exporting OTLP metrics: %s data points rejected: %s",
+				rejected, result.PartialSuccess.ErrorMessage,
+			)
+		}
+	}
+	return nil
+}
+
+// The types below are the OTLP/HTTP JSON encoding of an
+// ExportMetricsServiceRequest, restricted to gauges. As in the protobuf
+// JSON mapping, 64-bit integers are encoded as strings.
+
+type otlpExportRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+
+	// byName holds the resource's metrics, keyed by name.
+	byName map[string]*otlpMetric
+}
+
+// addDataPoint adds a data point to the named metric, adding the metric if
+// necessary.
+func (r *otlpResourceMetrics) addDataPoint(name, unit string, dp otlpNumberDataPoint) {
+	m, ok := r.byName[name]
+	if !ok {
+		m = &otlpMetric{Name: name, Unit: unit}
+		r.byName[name] = m
+		r.ScopeMetrics[0].Metrics = append(r.ScopeMetrics[0].Metrics, m)
+	}
+	m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope     `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Unit  string    `json:"unit,omitempty"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     *float64       `json:"asDouble,omitempty"`
+	AsInt        string         `json:"asInt,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
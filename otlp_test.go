@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otlpReceiver is an in-memory OTLP/HTTP receiver, recording the JSON
+// export requests it receives.
+type otlpReceiver struct {
+	*httptest.Server
+	requests []map[string]interface{}
+}
+
+func newOTLPReceiver(t *testing.T, response string) *otlpReceiver {
+	r := &otlpReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/v1/metrics", req.URL.Path)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		r.requests = append(r.requests, body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func Test_otlpOutput(t *testing.T) {
+	receiver := newOTLPReceiver(t, `{}`)
+	out, err := newOTLPOutput(context.Background(), nil, receiver.URL)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, out.Write(gobench.Document{
+		gobench.FieldExecutedAt:   timestamp,
+		gobench.FieldPkg:          "example.com/foo",
+		gobench.FieldName:         "BenchmarkFoo",
+		gobench.FieldFullName:     "BenchmarkFoo",
+		gobench.FieldGOOS:         "linux",
+		gobench.FieldGOARCH:       "amd64",
+		gobench.FieldGOMAXPROCS:   8,
+		gobench.FieldIterations:   100,
+		gobench.FieldNSPerOp:      10.5,
+		gobench.FieldAllocsPerOp:  uint64(2),
+		gobench.FieldExtraMetrics: map[string]float64{"events_sec": 42},
+		gobench.FieldTags:         map[string]string{"team": "apm"},
+		gobench.FieldHostname:     "builder",
+		gobench.FieldGit:          map[string]interface{}{gobench.FieldGitCommit: "abc123"},
+	}))
+	require.NoError(t, out.Write(gobench.Document{
+		gobench.FieldExecutedAt: timestamp,
+		gobench.FieldPkg:        "example.com/foo",
+		gobench.FieldName:       "BenchmarkBar",
+		gobench.FieldFullName:   "BenchmarkBar",
+		gobench.FieldIterations: 200,
+		gobench.FieldNSPerOp:    20.0,
+		gobench.FieldHostname:   "builder",
+		gobench.FieldGit:        map[string]interface{}{gobench.FieldGitCommit: "abc123"},
+	}))
+	require.Empty(t, receiver.requests)
+	require.NoError(t, out.Flush())
+	require.Len(t, receiver.requests, 1)
+
+	str := func(key, value string) map[string]interface{} {
+		return map[string]interface{}{"key": key, "value": map[string]interface{}{"stringValue": value}}
+	}
+	fooAttrs := []interface{}{
+		str("pkg", "example.com/foo"),
+		str("name", "BenchmarkFoo"),
+		str("full_name", "BenchmarkFoo"),
+		str("goos", "linux"),
+		str("goarch", "amd64"),
+		map[string]interface{}{"key": "gomaxprocs", "value": map[string]interface{}{"intValue": "8"}},
+		str("tags.team", "apm"),
+	}
+	barAttrs := []interface{}{
+		str("pkg", "example.com/foo"),
+		str("name", "BenchmarkBar"),
+		str("full_name", "BenchmarkBar"),
+	}
+	const timeUnixNano = "1705320000000000000"
+	metric := func(name, unit string, dataPoints ...interface{}) map[string]interface{} {
+		m := map[string]interface{}{
+			"name":  name,
+			"gauge": map[string]interface{}{"dataPoints": dataPoints},
+		}
+		if unit != "" {
+			m["unit"] = unit
+		}
+		return m
+	}
+	point := func(attrs []interface{}, key string, value interface{}) map[string]interface{} {
+		return map[string]interface{}{"attributes": attrs, "timeUnixNano": timeUnixNano, key: value}
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []interface{}{
+					str("service.name", "gobench"),
+					str("host.name", "builder"),
+					str("vcs.ref.head.revision", "abc123"),
+				},
+			},
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/elastic/gobench"},
+				"metrics": []interface{}{
+					metric("gobench.iterations", "{iteration}",
+						point(fooAttrs, "asInt", "100"),
+						point(barAttrs, "asInt", "200"),
+					),
+					metric("gobench.ns_per_op", "ns",
+						point(fooAttrs, "asDouble", 10.5),
+						point(barAttrs, "asDouble", 20.0),
+					),
+					metric("gobench.allocs_per_op", "{allocation}",
+						point(fooAttrs, "asInt", "2"),
+					),
+					metric("gobench.extra_metrics.events_sec", "",
+						point(fooAttrs, "asDouble", 42.0),
+					),
+				},
+			}},
+		}},
+	}, receiver.requests[0])
+}
+
+func Test_otlpOutputErrors(t *testing.T) {
+	doc := gobench.Document{
+		gobench.FieldExecutedAt: time.Now(),
+		gobench.FieldName:       "BenchmarkFoo",
+		gobench.FieldNSPerOp:    10.0,
+	}
+
+	receiver := newOTLPReceiver(t, `{"partialSuccess":{"rejectedDataPoints":"1","errorMessage":"bad data point"}}`)
+	out, err := newOTLPOutput(context.Background(), nil, receiver.URL)
+	require.NoError(t, err)
+	require.NoError(t, out.Write(doc))
+	assert.EqualError(t, out.Flush(), "error exporting OTLP metrics: 1 data points rejected: bad data point")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	out, err = newOTLPOutput(context.Background(), nil, srv.URL+"/otlp/v1/metrics")
+	require.NoError(t, err)
+	require.NoError(t, out.Write(doc))
+	assert.EqualError(t, out.Flush(), "error exporting OTLP metrics: 503 Service Unavailable: unavailable")
+
+	_, err = newOTLPOutput(context.Background(), nil, "localhost:4318")
+	assert.EqualError(t, err, `invalid OTLP endpoint "localhost:4318": must be an http or https URL`)
+}
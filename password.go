@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// readPasswordFile returns the contents of the file at path, without any
+// trailing newline.
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	password := strings.TrimRight(string(data), "\r\n")
+	if password == "" {
+		return "", errors.Errorf("%s is empty", path)
+	}
+	return password, nil
+}
+
+// needsPassword reports whether requests to Elasticsearch would use basic
+// authentication without a password: that is, when a username but no
+// password, API key or bearer token was given.
+func needsPassword(cfg inputConfig) bool {
+	return cfg.es.URL != "" && !cfg.dryRun &&
+		cfg.es.Username != "" && cfg.es.Password == "" &&
+		cfg.es.APIKey == "" && cfg.es.BearerToken == ""
+}
+
+// promptPassword prompts for the Elasticsearch password on w and reads it
+// from stdin without echoing it, if the password is needed and stdin is a
+// terminal. When stdin is the benchmark output piped from go test, it
+// does nothing.
+func promptPassword(cfg *inputConfig, stdin io.Reader, w io.Writer) error {
+	f, ok := stdin.(*os.File)
+	if !ok || !needsPassword(*cfg) || !term.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+	fmt.Fprintf(w, "Elasticsearch password for %s: ", cfg.es.Username)
+	password, err := term.ReadPassword(int(f.Fd()))
+	fmt.Fprintln(w)
+	if err != nil {
+		return errors.Wrap(err, "error reading password")
+	}
+	cfg.es.Password = string(password)
+	return nil
+}
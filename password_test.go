@@ -0,0 +1,92 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readInputConfigPasswordFile(t *testing.T) {
+	for name, content := range map[string]string{
+		"newline": "s3cret\n",
+		"crlf":    "s3cret\r\n",
+		"none":    "s3cret",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfigFile(t, "password", content)
+			cfg, err := testReadInputConfig(t, "-es-username", "elastic", "-es-password-file", path)
+			require.NoError(t, err)
+			assert.Equal(t, "s3cret", cfg.es.Password)
+		})
+	}
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("GOBENCH_ES_PASSWORD_FILE", writeConfigFile(t, "password", "s3cret\n"))
+		cfg, err := testReadInputConfig(t)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", cfg.es.Password)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		path := writeConfigFile(t, "password", "\n")
+		_, err := testReadInputConfig(t, "-es-password-file", path)
+		assert.EqualError(t, err, "error reading password file: "+path+" is empty")
+	})
+
+	t.Run("exclusive", func(t *testing.T) {
+		path := writeConfigFile(t, "password", "s3cret\n")
+		_, err := testReadInputConfig(t, "-es-password", "changeme", "-es-password-file", path)
+		assert.EqualError(t, err, "-es-password and -es-password-file are mutually exclusive")
+	})
+}
+
+func Test_promptPasswordNotTerminal(t *testing.T) {
+	// Benchmark output piped to stdin must never be consumed as a
+	// password.
+	cfg := inputConfig{}
+	cfg.es.URL = "http://localhost:9200"
+	cfg.es.Username = "elastic"
+	require.True(t, needsPassword(cfg))
+
+	var stderr bytes.Buffer
+	require.NoError(t, promptPassword(&cfg, strings.NewReader("BenchmarkFoo 1 1 ns/op\n"), &stderr))
+	assert.Empty(t, cfg.es.Password)
+	assert.Empty(t, stderr.String())
+}
+
+func Test_needsPassword(t *testing.T) {
+	newConfig := func(modify func(cfg *inputConfig)) inputConfig {
+		cfg := inputConfig{}
+		cfg.es.URL = "http://localhost:9200"
+		cfg.es.Username = "elastic"
+		modify(&cfg)
+		return cfg
+	}
+	assert.True(t, needsPassword(newConfig(func(cfg *inputConfig) {})))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.es.Password = "s3cret" })))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.es.APIKey = "key" })))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.es.BearerToken = "token" })))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.es.Username = "" })))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.es.URL = "" })))
+	assert.False(t, needsPassword(newConfig(func(cfg *inputConfig) { cfg.dryRun = true })))
+}
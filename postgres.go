@@ -0,0 +1,153 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// postgresMaxParams is the maximum number of parameters in a PostgreSQL
+// statement, which limits the number of rows in each batch.
+const postgresMaxParams = 65535
+
+// postgresMaxBatchSize is the maximum number of rows inserted by each
+// statement.
+var postgresMaxBatchSize = postgresMaxParams / len(sqlColumns)
+
+// postgresOutput is an Output which inserts each document as a row of a
+// PostgreSQL table. Rows are buffered and inserted batchSize at a time in
+// a single transaction, which is committed by Flush.
+type postgresOutput struct {
+	db        *sql.DB
+	tx        *sql.Tx
+	table     string
+	batchSize int
+	batch     []interface{}
+}
+
+// openPostgresOutput connects to the PostgreSQL database described by dsn,
+// and creates table if it does not exist. The table name may be qualified
+// by a schema name, e.g. "public.benchmarks".
+func openPostgresOutput(dsn, table string, batchSize int) (*postgresOutput, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	table = quotePostgresTable(table)
+	if _, err := db.Exec(postgresCreateTable(table)); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "error creating PostgreSQL table %s", table)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &postgresOutput{db: db, tx: tx, table: table, batchSize: batchSize}, nil
+}
+
+// quotePostgresTable quotes each part of the possibly schema-qualified
+// table name.
+func quotePostgresTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// postgresCreateTable returns the statement creating the quoted table, if
+// it does not exist.
+func postgresCreateTable(table string) string {
+	defs := make([]string, len(sqlColumns))
+	for i, col := range sqlColumns {
+		defs[i] = col.name + " " + col.postgresType
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(defs, ",\n\t"))
+}
+
+// postgresInsert returns the statement inserting the given number of rows
+// into the quoted table.
+func postgresInsert(table string, rows int) string {
+	names := make([]string, len(sqlColumns))
+	for i, col := range sqlColumns {
+		names[i] = col.name
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(names, ", "))
+	param := 1
+	for row := 0; row < rows; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for i := range sqlColumns {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", param)
+			param++
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+func (o *postgresOutput) Write(doc gobench.Document) error {
+	for _, col := range sqlColumns {
+		value, err := col.value(doc)
+		if err != nil {
+			return errors.Wrapf(err, "error encoding %s", col.name)
+		}
+		o.batch = append(o.batch, value)
+	}
+	if len(o.batch) >= o.batchSize*len(sqlColumns) {
+		return o.insertBatch()
+	}
+	return nil
+}
+
+// insertBatch inserts the buffered rows.
+func (o *postgresOutput) insertBatch() error {
+	rows := len(o.batch) / len(sqlColumns)
+	if rows == 0 {
+		return nil
+	}
+	if _, err := o.tx.Exec(postgresInsert(o.table, rows), o.batch...); err != nil {
+		return errors.Wrapf(err, "error inserting into %s", o.table)
+	}
+	o.batch = o.batch[:0]
+	return nil
+}
+
+// Flush inserts any buffered rows, commits the transaction, and closes
+// the database.
+func (o *postgresOutput) Flush() error {
+	defer o.db.Close()
+	if err := o.insertBatch(); err != nil {
+		o.tx.Rollback()
+		return err
+	}
+	return o.tx.Commit()
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_quotePostgresTable(t *testing.T) {
+	assert.Equal(t, `"benchmarks"`, quotePostgresTable("benchmarks"))
+	assert.Equal(t, `"perf"."Go Benchmarks"`, quotePostgresTable("perf.Go Benchmarks"))
+	assert.Equal(t, `"a""b"`, quotePostgresTable(`a"b`))
+}
+
+func Test_postgresCreateTable(t *testing.T) {
+	stmt := postgresCreateTable(`"benchmarks"`)
+	assert.True(t, strings.HasPrefix(stmt, `CREATE TABLE IF NOT EXISTS "benchmarks" (`), stmt)
+	assert.Contains(t, stmt, "\texecuted_at timestamptz NOT NULL,\n")
+	assert.Contains(t, stmt, "\tns_per_op double precision,\n")
+	assert.Contains(t, stmt, "\tallocs_per_op bigint,\n")
+	assert.Contains(t, stmt, "\textra_metrics jsonb,\n")
+	assert.True(t, strings.HasSuffix(stmt, "\ttags jsonb\n)"), stmt)
+}
+
+func Test_postgresInsert(t *testing.T) {
+	n := len(sqlColumns)
+	stmt := postgresInsert(`"benchmarks"`, 2)
+	assert.True(t, strings.HasPrefix(stmt, `INSERT INTO "benchmarks" (executed_at, pkg, name, `), stmt)
+	assert.Contains(t, stmt, ", tags) VALUES ($1, $2, ")
+	assert.Contains(t, stmt, fmt.Sprintf(", $%d), ($%d, ", n, n+1))
+	assert.True(t, strings.HasSuffix(stmt, fmt.Sprintf(", $%d)", 2*n)), stmt)
+}
+
+// Test_runPostgres inserts benchmarks into the PostgreSQL database given by
+// GOBENCH_TEST_POSTGRES_DSN, and is skipped if that is not set.
+func Test_runPostgres(t *testing.T) {
+	dsn := os.Getenv("GOBENCH_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("GOBENCH_TEST_POSTGRES_DSN not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	table := fmt.Sprintf("gobench_test_%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Exec("DROP TABLE " + table) })
+
+	const input = `goos: linux
+goarch: amd64
+pkg: example.com/foo
+BenchmarkFoo-8   	100	10 ns/op	16 B/op	1 allocs/op
+BenchmarkBar-8   	200	15.5 ns/op	42 events/sec
+BenchmarkBaz-8   	300	20 ns/op
+PASS
+`
+	cfg := inputConfig{
+		es:                gobench.Config{Index: "gobench"},
+		postgres:          dsn,
+		postgresTable:     table,
+		postgresBatchSize: 2,
+		tags:              map[string]string{"team": "apm"},
+		timestamp:         time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+	}
+	// Running twice checks that creating the table is idempotent.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, run(context.Background(), cfg, strings.NewReader(input), io.Discard))
+	}
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM "+table).Scan(&count))
+	assert.Equal(t, 6, count)
+
+	var executedAt time.Time
+	var nsPerOp float64
+	var team string
+	require.NoError(t, db.QueryRow(
+		"SELECT executed_at, ns_per_op, tags->>'team' FROM "+table+" WHERE extra_metrics->>'events_sec' = '42' LIMIT 1",
+	).Scan(&executedAt, &nsPerOp, &team))
+	assert.True(t, cfg.timestamp.Equal(executedAt))
+	assert.Equal(t, 15.5, nsPerOp)
+	assert.Equal(t, "apm", team)
+}
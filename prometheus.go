@@ -0,0 +1,217 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+const prometheusMetricPrefix = "gobench_"
+
+var (
+	prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	prometheusHelp = map[string]string{
+		gobench.FieldIterations:        "Number of iterations the benchmark ran for.",
+		gobench.FieldNSPerOp:           "Nanoseconds per benchmark iteration.",
+		gobench.FieldMBPerS:            "Megabytes processed per second.",
+		gobench.FieldAllocedBytesPerOp: "Bytes allocated per benchmark iteration.",
+		gobench.FieldAllocsPerOp:       "Allocations per benchmark iteration.",
+	}
+)
+
+// prometheusCoreLabels are the labels identifying each benchmark, which
+// user-defined tags must not replace.
+var prometheusCoreLabels = []string{
+	gobench.FieldPkg,
+	gobench.FieldName,
+	gobench.FieldGOOS,
+	gobench.FieldGOARCH,
+	gobench.FieldCPU,
+}
+
+// prometheusFormat encodes benchmark results in the Prometheus text
+// exposition format, suitable for the node_exporter textfile collector.
+//
+// Each benchmark metric is written as a gauge named "gobench_<metric>",
+// labelled with the package, name, goos, goarch, cpu and user-defined tags.
+// Results are buffered until flush, since all samples of a metric family
+// must be written together. Repeated runs of a benchmark, e.g. with
+// "go test -count", are combined as by -aggregate, since samples with the
+// same labels are invalid.
+type prometheusFormat struct {
+	w        io.Writer
+	runs     map[string][]gobench.Benchmark
+	order    []string
+	families map[string]*prometheusFamily
+}
+
+type prometheusFamily struct {
+	help    string
+	samples []prometheusSample
+}
+
+type prometheusSample struct {
+	labels string
+	value  float64
+}
+
+func newPrometheusFormat(w io.Writer) *prometheusFormat {
+	return &prometheusFormat{
+		w:        w,
+		runs:     make(map[string][]gobench.Benchmark),
+		families: make(map[string]*prometheusFamily),
+	}
+}
+
+func (f *prometheusFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	labels := make(map[string]string, len(tags)+len(prometheusCoreLabels))
+	for key, value := range tags {
+		labels[sanitizePrometheusName(key)] = value
+	}
+	// The core labels take precedence over any user-defined tags with
+	// the same names, which are rejected by validateFormatTags.
+	labels[gobench.FieldPkg] = pkg
+	labels[gobench.FieldName] = b.Name
+	labels[gobench.FieldGOOS] = goos
+	labels[gobench.FieldGOARCH] = goarch
+	labels[gobench.FieldCPU] = cpu
+	labelNames := make([]string, 0, len(labels))
+	for name, value := range labels {
+		if value != "" {
+			labelNames = append(labelNames, name)
+		}
+	}
+	sort.Strings(labelNames)
+	var sb strings.Builder
+	for i, name := range labelNames {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(prometheusLabelEscaper.Replace(labels[name]))
+		sb.WriteByte('"')
+	}
+	labelString := sb.String()
+
+	if _, ok := f.runs[labelString]; !ok {
+		f.order = append(f.order, labelString)
+	}
+	f.runs[labelString] = append(f.runs[labelString], b)
+	return nil
+}
+
+// addSamples adds the samples of each metric of b, with the given labels,
+// to their families.
+func (f *prometheusFormat) addSamples(b gobench.Benchmark, labelString string) {
+	add := func(metric, help string, value float64) {
+		name := prometheusMetricPrefix + sanitizePrometheusName(metric)
+		family, ok := f.families[name]
+		if !ok {
+			family = &prometheusFamily{help: help}
+			f.families[name] = family
+		}
+		family.samples = append(family.samples, prometheusSample{labels: labelString, value: value})
+	}
+	add(gobench.FieldIterations, prometheusHelp[gobench.FieldIterations], float64(b.N))
+	if b.Measured&parse.NsPerOp != 0 {
+		add(gobench.FieldNSPerOp, prometheusHelp[gobench.FieldNSPerOp], b.NsPerOp)
+	}
+	if b.Measured&parse.MBPerS != 0 {
+		add(gobench.FieldMBPerS, prometheusHelp[gobench.FieldMBPerS], b.MBPerS)
+	}
+	if b.Measured&parse.AllocedBytesPerOp != 0 {
+		add(gobench.FieldAllocedBytesPerOp, prometheusHelp[gobench.FieldAllocedBytesPerOp], float64(b.AllocedBytesPerOp))
+	}
+	if b.Measured&parse.AllocsPerOp != 0 {
+		add(gobench.FieldAllocsPerOp, prometheusHelp[gobench.FieldAllocsPerOp], float64(b.AllocsPerOp))
+	}
+	for key, value := range b.Extra {
+		add(key, "Extra benchmark metric "+key+".", value)
+	}
+}
+
+func (f *prometheusFormat) flush() error {
+	for _, labelString := range f.order {
+		runs := f.runs[labelString]
+		b := runs[0]
+		if len(runs) > 1 {
+			b = aggregate(runs)
+		}
+		f.addSamples(b, labelString)
+	}
+	f.runs = make(map[string][]gobench.Benchmark)
+	f.order = nil
+
+	names := make([]string, 0, len(f.families))
+	for name := range f.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := bufio.NewWriter(f.w)
+	for _, name := range names {
+		family := f.families[name]
+		w.WriteString("# HELP " + name + " " + family.help + "\n")
+		w.WriteString("# TYPE " + name + " gauge\n")
+		for _, sample := range family.samples {
+			w.WriteString(name)
+			if sample.labels != "" {
+				w.WriteString("{" + sample.labels + "}")
+			}
+			w.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+		}
+	}
+	f.families = make(map[string]*prometheusFamily)
+	return w.Flush()
+}
+
+// sanitizePrometheusName replaces characters which are not valid in
+// Prometheus metric and label names with underscores, and prefixes
+// names beginning with a digit with an underscore.
+func sanitizePrometheusName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				// Names must not begin with a digit.
+				sb.WriteByte('_')
+			}
+		default:
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+func Test_prometheusFormat(t *testing.T) {
+	var buf bytes.Buffer
+	f := newPrometheusFormat(&buf)
+	for _, b := range []gobench.Benchmark{{
+		Benchmark: parse.Benchmark{
+			Name: "BenchmarkFoo-8", N: 100, NsPerOp: 12.5, AllocsPerOp: 2,
+			Measured: parse.NsPerOp | parse.AllocsPerOp,
+		},
+		Extra: map[string]float64{"events/sec": 1000},
+	}, {
+		Benchmark: parse.Benchmark{Name: `BenchmarkBar/"quoted"\path`, N: 200, NsPerOp: 1e6, Measured: parse.NsPerOp},
+	}} {
+		err := f.encode(b, "example.com/pkg", "linux", "amd64", "", map[string]string{"ci-job": "1\n2"}, time.Now())
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.flush())
+
+	labels := func(name string) string {
+		return `{ci_job="1\n2",goarch="amd64",goos="linux",name="` + name + `",pkg="example.com/pkg"}`
+	}
+	foo := labels("BenchmarkFoo-8")
+	bar := labels(`BenchmarkBar/\"quoted\"\\path`)
+	assert.Equal(t, `# HELP gobench_allocs_per_op Allocations per benchmark iteration.
+# TYPE gobench_allocs_per_op gauge
+gobench_allocs_per_op`+foo+` 2
+# HELP gobench_events_sec Extra benchmark metric events/sec.
+# TYPE gobench_events_sec gauge
+gobench_events_sec`+foo+` 1000
+# HELP gobench_iterations Number of iterations the benchmark ran for.
+# TYPE gobench_iterations gauge
+gobench_iterations`+foo+` 100
+gobench_iterations`+bar+` 200
+# HELP gobench_ns_per_op Nanoseconds per benchmark iteration.
+# TYPE gobench_ns_per_op gauge
+gobench_ns_per_op`+foo+` 12.5
+gobench_ns_per_op`+bar+` 1e+06
+`, buf.String())
+}
+
+func Test_sanitizePrometheusName(t *testing.T) {
+	assert.Equal(t, "events_sec", sanitizePrometheusName("events_sec"))
+	assert.Equal(t, "ci_job_id", sanitizePrometheusName("ci-job.id"))
+	assert.Equal(t, "_9lives", sanitizePrometheusName("9lives"))
+}
+
+func Test_prometheusFormatRepeatedRuns(t *testing.T) {
+	input := `pkg: example.com/pkg
+BenchmarkFoo-8   	     100	        10.0 ns/op
+BenchmarkFoo-8   	     100	        20.0 ns/op
+BenchmarkBar-8   	     100	         5.0 ns/op
+`
+	code, stdout, _ := testGobenchMain(t, input, "-format", formatPrometheus, "-no-host", "-no-vcs")
+	require.Equal(t, exitOK, code)
+	assert.Equal(t, `# HELP gobench_iterations Number of iterations the benchmark ran for.
+# TYPE gobench_iterations gauge
+gobench_iterations{name="BenchmarkFoo-8",pkg="example.com/pkg"} 200
+gobench_iterations{name="BenchmarkBar-8",pkg="example.com/pkg"} 100
+# HELP gobench_ns_per_op Nanoseconds per benchmark iteration.
+# TYPE gobench_ns_per_op gauge
+gobench_ns_per_op{name="BenchmarkFoo-8",pkg="example.com/pkg"} 15
+gobench_ns_per_op{name="BenchmarkBar-8",pkg="example.com/pkg"} 5
+`, stdout)
+}
+
+func Test_prometheusFormatCoreLabels(t *testing.T) {
+	var buf bytes.Buffer
+	f := newPrometheusFormat(&buf)
+	b := gobench.Benchmark{Benchmark: parse.Benchmark{Name: "BenchmarkFoo", N: 10}}
+	require.NoError(t, f.encode(b, "example.com/pkg", "", "", "", map[string]string{"name": "x"}, time.Now()))
+	require.NoError(t, f.flush())
+	assert.Contains(t, buf.String(), `gobench_iterations{name="BenchmarkFoo",pkg="example.com/pkg"} 10`)
+
+	_, err := testReadInputConfig(t, "-format", formatPrometheus, "-tag", "pkg=x")
+	assert.EqualError(t, err, `invalid tag key "pkg": reserved by -format prometheus`)
+	_, err = testReadInputConfig(t, "-format", formatPrometheus, "-tag", "go-os=linux")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,71 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+)
+
+// sortedFormat is an outputFormat which buffers all benchmarks until it
+// is flushed, and then encodes them with out in order of their execution
+// time. Benchmarks with the same execution time keep their input order.
+type sortedFormat struct {
+	out        outputFormat
+	benchmarks []sortedBenchmark
+}
+
+// sortedBenchmark holds the arguments of a call to sortedFormat.encode.
+type sortedBenchmark struct {
+	b                      gobench.Benchmark
+	pkg, goos, goarch, cpu string
+	tags                   map[string]string
+	timestamp              time.Time
+}
+
+func newSortedFormat(out outputFormat) *sortedFormat {
+	return &sortedFormat{out: out}
+}
+
+func (f *sortedFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	f.benchmarks = append(f.benchmarks, sortedBenchmark{
+		b: b, pkg: pkg, goos: goos, goarch: goarch, cpu: cpu,
+		tags: tags, timestamp: timestamp,
+	})
+	return nil
+}
+
+func (f *sortedFormat) flush() error {
+	sort.SliceStable(f.benchmarks, func(i, j int) bool {
+		return f.benchmarks[i].timestamp.Before(f.benchmarks[j].timestamp)
+	})
+	for _, s := range f.benchmarks {
+		if err := f.out.encode(s.b, s.pkg, s.goos, s.goarch, s.cpu, s.tags, s.timestamp); err != nil {
+			return err
+		}
+	}
+	f.benchmarks = nil
+	return f.out.flush()
+}
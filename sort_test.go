@@ -0,0 +1,81 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/benchmark/parse"
+)
+
+// recordingFormat is an outputFormat which records the names and
+// timestamps of the benchmarks encoded with it.
+type recordingFormat struct {
+	names      []string
+	timestamps []time.Time
+	flushed    bool
+}
+
+func (f *recordingFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	f.names = append(f.names, b.Name)
+	f.timestamps = append(f.timestamps, timestamp)
+	return nil
+}
+
+func (f *recordingFormat) flush() error {
+	f.flushed = true
+	return nil
+}
+
+func Test_sortedFormat(t *testing.T) {
+	base := time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC)
+	var out recordingFormat
+	sorted := newSortedFormat(&out)
+	for _, b := range []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"BenchmarkC", 2 * time.Hour},
+		{"BenchmarkA", 0},
+		{"BenchmarkD", 3 * time.Hour},
+		{"BenchmarkB1", time.Hour},
+		{"BenchmarkB2", time.Hour},
+	} {
+		require.NoError(t, sorted.encode(
+			gobench.Benchmark{Benchmark: parse.Benchmark{Name: b.name}},
+			"example.com/foo", "linux", "amd64", "", nil, base.Add(b.offset),
+		))
+	}
+	assert.Empty(t, out.names)
+
+	require.NoError(t, sorted.flush())
+	assert.True(t, out.flushed)
+	assert.Equal(t, []string{"BenchmarkA", "BenchmarkB1", "BenchmarkB2", "BenchmarkC", "BenchmarkD"}, out.names)
+	assert.Equal(t, []time.Time{
+		base, base.Add(time.Hour), base.Add(time.Hour), base.Add(2 * time.Hour), base.Add(3 * time.Hour),
+	}, out.timestamps)
+}
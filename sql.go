@@ -0,0 +1,105 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+)
+
+// sqlColumn is a column of the tables written by sqliteOutput and
+// postgresOutput, with its type in each database, holding the value
+// obtained from each document by value. A nil value is stored as NULL.
+type sqlColumn struct {
+	name         string
+	sqliteType   string
+	postgresType string
+	value        func(doc gobench.Document) (interface{}, error)
+}
+
+// sqlColumns are the columns of the benchmarks tables, in order. Extra
+// metrics and tags, whose keys vary, are stored as JSON objects.
+var sqlColumns = []sqlColumn{
+	{gobench.FieldExecutedAt, "TEXT NOT NULL", "timestamptz NOT NULL", docTime(gobench.FieldExecutedAt)},
+	{gobench.FieldPkg, "TEXT", "text", docField(gobench.FieldPkg)},
+	{gobench.FieldName, "TEXT NOT NULL", "text NOT NULL", docField(gobench.FieldName)},
+	{gobench.FieldFullName, "TEXT NOT NULL", "text NOT NULL", docField(gobench.FieldFullName)},
+	{gobench.FieldGOOS, "TEXT", "text", docField(gobench.FieldGOOS)},
+	{gobench.FieldGOARCH, "TEXT", "text", docField(gobench.FieldGOARCH)},
+	{gobench.FieldCPU, "TEXT", "text", docField(gobench.FieldCPU)},
+	{gobench.FieldGOMAXPROCS, "INTEGER", "bigint", docField(gobench.FieldGOMAXPROCS)},
+	{gobench.FieldIterations, "INTEGER", "bigint", docField(gobench.FieldIterations)},
+	{gobench.FieldNSPerOp, "REAL", "double precision", docField(gobench.FieldNSPerOp)},
+	{gobench.FieldMBPerS, "REAL", "double precision", docField(gobench.FieldMBPerS)},
+	{gobench.FieldAllocedBytesPerOp, "INTEGER", "bigint", docField(gobench.FieldAllocedBytesPerOp)},
+	{gobench.FieldAllocsPerOp, "INTEGER", "bigint", docField(gobench.FieldAllocsPerOp)},
+	{gobench.FieldHostname, "TEXT", "text", docField(gobench.FieldHostname)},
+	{gobench.FieldGoVersion, "TEXT", "text", docField(gobench.FieldGoVersion)},
+	{"vcs_commit", "TEXT", "text", docCommit},
+	{gobench.FieldExtraMetrics, "TEXT", "jsonb", docJSON(gobench.FieldExtraMetrics)},
+	{gobench.FieldTags, "TEXT", "jsonb", docJSON(gobench.FieldTags)},
+}
+
+// docField returns a column value function which returns the named
+// document field.
+func docField(field string) func(gobench.Document) (interface{}, error) {
+	return func(doc gobench.Document) (interface{}, error) {
+		return doc[field], nil
+	}
+}
+
+// docTime returns a column value function which returns the named
+// time.Time document field in RFC 3339 format, so that it sorts and
+// compares correctly as text in SQLite, and is parsed by PostgreSQL.
+func docTime(field string) func(gobench.Document) (interface{}, error) {
+	return func(doc gobench.Document) (interface{}, error) {
+		t, ok := doc[field].(time.Time)
+		if !ok {
+			return nil, nil
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	}
+}
+
+// docJSON returns a column value function which returns the named document
+// field encoded as JSON.
+func docJSON(field string) func(gobench.Document) (interface{}, error) {
+	return func(doc gobench.Document) (interface{}, error) {
+		value, ok := doc[field]
+		if !ok {
+			return nil, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+}
+
+// docCommit returns the git or Mercurial commit of the document.
+func docCommit(doc gobench.Document) (interface{}, error) {
+	for _, field := range []string{gobench.FieldGit, gobench.FieldHg} {
+		if vcs, ok := doc[field].(map[string]interface{}); ok {
+			return vcs[gobench.FieldGitCommit], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/gobench/gobench"
+	_ "github.com/mattn/go-sqlite3" // registers the "sqlite3" driver
+	"github.com/pkg/errors"
+)
+
+// sqliteTable is the name of the table written by sqliteOutput.
+const sqliteTable = "benchmarks"
+
+// sqliteOutput is an Output which inserts each document as a row of the
+// benchmarks table of a SQLite database. The rows are inserted in a single
+// transaction, which is committed by Flush.
+type sqliteOutput struct {
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+// openSQLiteOutput opens the SQLite database at path, creating it and the
+// benchmarks table if they do not exist.
+func openSQLiteOutput(path string) (*sqliteOutput, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := newSQLiteOutput(db)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "error opening SQLite database %s", path)
+	}
+	return out, nil
+}
+
+func newSQLiteOutput(db *sql.DB) (*sqliteOutput, error) {
+	names := make([]string, len(sqlColumns))
+	defs := make([]string, len(sqlColumns))
+	for i, col := range sqlColumns {
+		names[i] = col.name
+		defs[i] = col.name + " " + col.sqliteType
+	}
+	schema := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", sqliteTable, strings.Join(defs, ",\n\t"))
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		sqliteTable,
+		strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "),
+	))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &sqliteOutput{db: db, tx: tx, stmt: stmt}, nil
+}
+
+func (o *sqliteOutput) Write(doc gobench.Document) error {
+	values := make([]interface{}, len(sqlColumns))
+	for i, col := range sqlColumns {
+		value, err := col.value(doc)
+		if err != nil {
+			return errors.Wrapf(err, "error encoding %s", col.name)
+		}
+		values[i] = value
+	}
+	_, err := o.stmt.Exec(values...)
+	return err
+}
+
+// Flush commits the inserted rows, and closes the database.
+func (o *sqliteOutput) Flush() error {
+	defer o.db.Close()
+	if err := o.stmt.Close(); err != nil {
+		o.tx.Rollback()
+		return err
+	}
+	return o.tx.Commit()
+}
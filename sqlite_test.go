@@ -0,0 +1,104 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runSQLite(t *testing.T) {
+	const input = `goos: linux
+goarch: amd64
+pkg: example.com/foo
+BenchmarkFoo-8   	100	10 ns/op	16 B/op	1 allocs/op
+BenchmarkBar/size=10-8   	200	15.5 ns/op	42 events/sec
+PASS
+`
+	path := filepath.Join(t.TempDir(), "gobench.db")
+	cfg := inputConfig{
+		es:        gobench.Config{Index: "gobench"},
+		sqlite:    path,
+		tags:      map[string]string{"team": "apm"},
+		timestamp: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+	}
+	// Running twice checks that creating the schema is idempotent.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, run(context.Background(), cfg, strings.NewReader(input), io.Discard))
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT executed_at, pkg, name, full_name, goos, goarch, gomaxprocs,
+		       iterations, ns_per_op, alloced_bytes_per_op, allocs_per_op,
+		       extra_metrics, tags
+		FROM benchmarks
+		WHERE executed_at = '2024-01-15T12:00:00Z'
+		ORDER BY rowid`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	type row struct {
+		executedAt, pkg, name, fullName, goos, goarch string
+		gomaxprocs, iterations                        int
+		nsPerOp                                       float64
+		allocedBytesPerOp, allocsPerOp                sql.NullInt64
+		extraMetrics                                  sql.NullString
+		tags                                          string
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(
+			&r.executedAt, &r.pkg, &r.name, &r.fullName, &r.goos, &r.goarch, &r.gomaxprocs,
+			&r.iterations, &r.nsPerOp, &r.allocedBytesPerOp, &r.allocsPerOp,
+			&r.extraMetrics, &r.tags,
+		))
+		got = append(got, r)
+	}
+	require.NoError(t, rows.Err())
+
+	foo := row{
+		executedAt: "2024-01-15T12:00:00Z", pkg: "example.com/foo",
+		name: "BenchmarkFoo", fullName: "BenchmarkFoo", goos: "linux", goarch: "amd64",
+		gomaxprocs: 8, iterations: 100, nsPerOp: 10,
+		allocedBytesPerOp: sql.NullInt64{Int64: 16, Valid: true},
+		allocsPerOp:       sql.NullInt64{Int64: 1, Valid: true},
+		tags:              `{"team":"apm"}`,
+	}
+	bar := row{
+		executedAt: "2024-01-15T12:00:00Z", pkg: "example.com/foo",
+		name: "BenchmarkBar", fullName: "BenchmarkBar/size=10", goos: "linux", goarch: "amd64",
+		gomaxprocs: 8, iterations: 200, nsPerOp: 15.5,
+		extraMetrics: sql.NullString{String: `{"events_sec":42}`, Valid: true},
+		tags:         `{"team":"apm"}`,
+	}
+	assert.Equal(t, []row{foo, bar, foo, bar}, got)
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/pkg/errors"
+)
+
+// summary counts the lines, benchmarks and documents processed by a run,
+// for reporting at the end of the run.
+type summary struct {
+	lines       int
+	benchmarks  int
+	parseErrors int
+
+	// skipped is the number of benchmarks skipped by -min-ns-per-op.
+	skipped int
+
+	// testFailed is set if the benchmark output reported a failure.
+	testFailed bool
+
+	// written is the number of documents encoded in the output format.
+	written int
+
+	// indexed and failed are the numbers of documents successfully and
+	// unsuccessfully indexed into Elasticsearch, if es is true.
+	es      bool
+	indexed int
+	failed  int
+}
+
+func (s *summary) String() string {
+	msg := fmt.Sprintf(
+		"parsed %d lines: %d benchmarks, %d parse errors; ",
+		s.lines, s.benchmarks, s.parseErrors,
+	)
+	if s.skipped > 0 {
+		msg += fmt.Sprintf("skipped %d benchmarks; ", s.skipped)
+	}
+	if s.es {
+		return msg + fmt.Sprintf("indexed %d documents, %d failed", s.indexed, s.failed)
+	}
+	return msg + fmt.Sprintf("wrote %d documents", s.written)
+}
+
+// LogValue implements slog.LogValuer, logging the summary as a group.
+func (s *summary) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("lines", s.lines),
+		slog.Int("benchmarks", s.benchmarks),
+		slog.Int("parse_errors", s.parseErrors),
+	}
+	if s.skipped > 0 {
+		attrs = append(attrs, slog.Int("skipped", s.skipped))
+	}
+	if s.testFailed {
+		attrs = append(attrs, slog.Bool("test_failed", true))
+	}
+	if s.es {
+		attrs = append(attrs, slog.Int("indexed", s.indexed), slog.Int("failed", s.failed))
+	} else {
+		attrs = append(attrs, slog.Int("written", s.written))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// emptyErr returns an error if no benchmarks were parsed, distinguishing
+// empty input from input without any benchmark results.
+func (s *summary) emptyErr() error {
+	switch {
+	case s.benchmarks > 0:
+		return nil
+	case s.lines == 0:
+		return errors.New("no benchmarks parsed: the input was empty")
+	}
+	return errors.Errorf("no benchmarks parsed from %d lines of input", s.lines)
+}
+
+// wrap returns an outputFormat which counts the documents encoded by out.
+func (s *summary) wrap(out outputFormat) outputFormat {
+	return summaryFormat{outputFormat: out, summary: s}
+}
+
+type summaryFormat struct {
+	outputFormat
+	summary *summary
+}
+
+func (f summaryFormat) encode(
+	b gobench.Benchmark,
+	pkg, goos, goarch, cpu string,
+	tags map[string]string,
+	timestamp time.Time,
+) error {
+	if err := f.outputFormat.encode(b, pkg, goos, goarch, cpu, tags, timestamp); err != nil {
+		return err
+	}
+	f.summary.written++
+	return nil
+}
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const summaryInput = `goos: linux
+pkg: example.com/foo
+BenchmarkFoo-8   	100	10 ns/op
+BenchmarkBar
+BenchmarkBar-8   	many	10 ns/op
+BenchmarkBaz-8   	100	20 ns/op
+PASS
+`
+
+func Test_summaryStdout(t *testing.T) {
+	var sum summary
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}}
+	require.NoError(t, output(context.Background(), cfg, strings.NewReader(summaryInput), io.Discard, nil, &sum))
+	assert.Equal(t, summary{lines: 7, benchmarks: 2, parseErrors: 1, written: 2}, sum)
+	assert.Equal(t, "parsed 7 lines: 2 benchmarks, 1 parse errors; wrote 2 documents", sum.String())
+}
+
+func Test_summaryMinNsPerOp(t *testing.T) {
+	var sum summary
+	var buf strings.Builder
+	cfg := inputConfig{es: gobench.Config{Index: "gobench"}, minNsPerOp: 15}
+	require.NoError(t, output(context.Background(), cfg, strings.NewReader(summaryInput), &buf, nil, &sum))
+	assert.Equal(t, summary{lines: 7, benchmarks: 2, parseErrors: 1, skipped: 1, written: 1}, sum)
+	assert.Equal(t, "parsed 7 lines: 2 benchmarks, 1 parse errors; skipped 1 benchmarks; wrote 1 documents", sum.String())
+	assert.Contains(t, buf.String(), "BenchmarkBaz")
+	assert.NotContains(t, buf.String(), "BenchmarkFoo")
+}
+
+func Test_summaryElasticsearch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version":{"number":"8.0.0"}}`))
+		case "/_bulk":
+			w.Write([]byte(`{"errors":true,"items":[
+				{"index":{"status":201}},
+				{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}
+			]}`))
+		default:
+			w.Write([]byte(`{"acknowledged":true}`))
+		}
+	}))
+	defer srv.Close()
+
+	var sum summary
+	cfg := inputConfig{es: gobench.Config{URL: srv.URL, Index: "gobench"}}
+	err := output(context.Background(), cfg, strings.NewReader(summaryInput), io.Discard, nil, &sum)
+	assert.EqualError(t, err, "error executing bulk updates: bulk request 1: 1 of 2 bulk items failed: item 2 (BenchmarkBaz): status 400: mapper_parsing_exception: ")
+	assert.Equal(t, summary{lines: 7, benchmarks: 2, parseErrors: 1, written: 2, es: true, indexed: 1, failed: 1}, sum)
+	assert.Equal(t, "parsed 7 lines: 2 benchmarks, 1 parse errors; indexed 1 documents, 1 failed", sum.String())
+}
@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	inputText = "text"
+	inputJSON = "json"
+)
+
+// testEvent is an event emitted by "go test -json". See
+// https://pkg.go.dev/cmd/test2json for details.
+type testEvent struct {
+	Action  string
+	Package string
+	Output  string
+}
+
+// forEachTestEventLine reads a "go test -json" event stream from r,
+// reassembling the output of each package into lines and calling f with
+// each complete line and the package that produced it.
+//
+// Output events may contain partial lines, e.g. benchmark names are
+// printed before their results, so output is buffered per package until
+// a newline is seen. Lines in r which are not JSON are passed through
+// as-is, with an empty package.
+func forEachTestEventLine(r io.Reader, f func(pkg, line string) error) error {
+	partial := make(map[string]string)
+	emit := func(pkg, output string) error {
+		output = partial[pkg] + output
+		for {
+			i := strings.IndexByte(output, '\n')
+			if i == -1 {
+				break
+			}
+			if err := f(pkg, output[:i]); err != nil {
+				return err
+			}
+			output = output[i+1:]
+		}
+		partial[pkg] = output
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			if err := f("", scanner.Text()); err != nil {
+				return err
+			}
+			continue
+		}
+		if event.Action != "output" {
+			continue
+		}
+		if err := emit(event.Package, event.Output); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Flush any trailing partial lines.
+	pkgs := make([]string, 0, len(partial))
+	for pkg, output := range partial {
+		if output != "" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		if err := f(pkg, partial[pkg]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
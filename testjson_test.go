@@ -0,0 +1,90 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/elastic/gobench/gobench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_encodeBenchmarksJSONInput(t *testing.T) {
+	encode := func(t *testing.T, input string, r io.Reader) []map[string]interface{} {
+		var buf bytes.Buffer
+		out, err := newOutputFormat(formatJSON, &buf, gobench.Config{Index: "gobench"}, nil, gobench.DocumentOptions{})
+		require.NoError(t, err)
+		require.NoError(t, encodeBenchmarks(inputConfig{input: input}, r, out, new(summary)))
+
+		var docs []map[string]interface{}
+		decoder := json.NewDecoder(&buf)
+		for {
+			var doc map[string]interface{}
+			if err := decoder.Decode(&doc); err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			delete(doc, gobench.FieldExecutedAt)
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+
+	text, err := os.ReadFile("testdata/benchmark-result.txt")
+	require.NoError(t, err)
+	textDocs := encode(t, inputText, io.MultiReader(
+		strings.NewReader("goos: linux\ngoarch: amd64\npkg: github.com/elastic/gobench\n"),
+		bytes.NewReader(text),
+	))
+
+	f, err := os.Open("testdata/benchmark-result.json")
+	require.NoError(t, err)
+	defer f.Close()
+	jsonDocs := encode(t, inputJSON, f)
+
+	assert.Len(t, jsonDocs, 12)
+	assert.Equal(t, textDocs, jsonDocs)
+}
+
+func Test_forEachTestEventLine(t *testing.T) {
+	input := `{"Action":"output","Package":"a","Output":"BenchmarkA-8 \t"}
+{"Action":"output","Package":"b","Output":"BenchmarkB-8 \t"}
+{"Action":"output","Package":"a","Output":"100\t 10 ns/op\nPASS\n"}
+{"Action":"pass","Package":"a"}
+not json
+{"Action":"output","Package":"b","Output":"200\t 20 ns/op"}
+`
+	var lines []string
+	err := forEachTestEventLine(strings.NewReader(input), func(pkg, line string) error {
+		lines = append(lines, pkg+": "+line)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a: BenchmarkA-8 \t100\t 10 ns/op",
+		"a: PASS",
+		": not json",
+		"b: BenchmarkB-8 \t200\t 20 ns/op",
+	}, lines)
+}
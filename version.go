@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the version of gobench. It may be set when building with
+// -ldflags "-X main.version=v1.2.3"; otherwise the module version is read
+// from the build info, as for binaries installed with "go install".
+var version string
+
+// buildInfo describes the gobench binary.
+type buildInfo struct {
+	version   string
+	commit    string
+	modified  bool
+	goVersion string
+}
+
+// readBuildInfo returns the buildInfo of the running binary. Fields which
+// are unknown, e.g. the commit of a binary built outside a repository,
+// are empty.
+func readBuildInfo() buildInfo {
+	info := buildInfo{version: version, goVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.commit = setting.Value
+		case "vcs.modified":
+			info.modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// versionString returns the version recorded in documents, which is
+// "devel" for binaries without a version.
+func (info buildInfo) versionString() string {
+	if info.version == "" {
+		return "devel"
+	}
+	return info.version
+}
+
+// printVersion writes the version, commit and Go toolchain of gobench to w.
+func printVersion(w io.Writer) {
+	info := readBuildInfo()
+	fmt.Fprintf(w, "gobench %s\n", info.versionString())
+	if info.commit != "" {
+		modified := ""
+		if info.modified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(w, "commit: %s%s\n", info.commit, modified)
+	}
+	fmt.Fprintf(w, "go: %s %s/%s\n", info.goVersion, runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,78 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unreadableReader is an io.Reader which fails the test if it is read.
+type unreadableReader struct {
+	t *testing.T
+}
+
+func (r unreadableReader) Read([]byte) (int, error) {
+	r.t.Error("unexpected read from stdin")
+	return 0, io.EOF
+}
+
+func Test_gobenchMainVersion(t *testing.T) {
+	for name, args := range map[string][]string{
+		"default": {"-version"},
+		"command": {"print", "-version"},
+		// Other settings are neither read nor validated.
+		"invalid": {"-version", "-workers", "0", "missing.txt"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("gobench", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			var stdout, stderr bytes.Buffer
+			code := gobenchMain(fs, args, unreadableReader{t}, &stdout, &stderr)
+			assert.Equal(t, exitOK, code)
+			assert.Empty(t, stderr.String())
+			assert.True(t, strings.HasPrefix(stdout.String(), "gobench "), stdout.String())
+			assert.Contains(t, stdout.String(), "go: "+runtime.Version()+" "+runtime.GOOS+"/"+runtime.GOARCH+"\n")
+		})
+	}
+}
+
+func Test_buildInfoVersionString(t *testing.T) {
+	assert.Equal(t, "devel", buildInfo{}.versionString())
+	assert.Equal(t, "v1.2.3", buildInfo{version: "v1.2.3"}.versionString())
+
+	defer func(orig string) { version = orig }(version)
+	version = "v1.2.3"
+	assert.Equal(t, "v1.2.3", readBuildInfo().version)
+}
+
+func Test_documentFieldsGobenchVersion(t *testing.T) {
+	cfg, err := testReadInputConfig(t, "-no-vcs", "-no-host")
+	assert.NoError(t, err)
+	cfg.docOptions.Fields = documentFields(cfg)
+	docs := encodeDocs(t, cfg, "BenchmarkFoo-8\t100\t10 ns/op\n")
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, readBuildInfo().versionString(), docs[0]["gobench_version"])
+	}
+}